@@ -17,6 +17,10 @@ limitations under the License.
 package v1
 
 import (
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,26 +28,199 @@ const (
 	BookingScheduled  = "SCHEDULED"
 	BookingInProgress = "IN PROGRESS"
 	BookingFinished   = "FINISHED"
+	// BookingQueued is the status of queued bookings that wait for the bookings of other users to end.
+	BookingQueued = "QUEUED"
+	// BookingPreempted is the status of bookings that lost their resource to an overlapping booking with a higher priority.
+	BookingPreempted = "PREEMPTED"
+)
+
+// The actions recorded in the history of a booking.
+const (
+	// BookingActionExtended records an extension of the booking by spec.extend_by.
+	BookingActionExtended = "extended"
+	// BookingActionExtensionRejected records an extension that was rejected, as it would overlap another booking.
+	BookingActionExtensionRejected = "extension_rejected"
+	// BookingActionReleased records the early release of the booking by spec.release.
+	BookingActionReleased = "released"
+	// BookingActionPromoted records the promotion of a queued booking, which starts at the time of the promotion
+	// when it waited past its start.
+	BookingActionPromoted = "promoted"
+	// BookingActionPreempted records the preemption of the booking by a booking with a higher priority.
+	BookingActionPreempted = "preempted"
+)
+
+const (
+	NotificationEmail   = "email"
+	NotificationSlack   = "slack"
+	NotificationTeams   = "teams"
+	NotificationWebhook = "webhook"
 )
 
+// The booking events that notifications can be sent for.
+const (
+	// NotificationEventScheduled is sent once for bookings that start in the future.
+	NotificationEventScheduled = "scheduled"
+	// NotificationEventStarted is sent once the instances of the booked resource are running.
+	NotificationEventStarted = "started"
+	// NotificationEventExpiring is sent as a reminder before the booking ends, 20 minutes before by default.
+	NotificationEventExpiring = "expiring"
+	// NotificationEventEnded is sent when the booking ends.
+	NotificationEventEnded = "ended"
+	// NotificationEventStartFailed is sent when the instances of the booked resource fail to start.
+	NotificationEventStartFailed = "start_failed"
+	// NotificationEventCancelled is sent when a booking is deleted before it ends.
+	NotificationEventCancelled = "cancelled"
+	// NotificationEventPromoted is sent when a queued booking stops waiting, as the bookings ahead of it ended.
+	NotificationEventPromoted = "promoted"
+	// NotificationEventPreempted is sent when the booking is preempted by a booking with a higher priority.
+	NotificationEventPreempted = "preempted"
+	// NotificationEventUpdated is sent when the end of the booking changes, as it is extended or released.
+	NotificationEventUpdated = "updated"
+)
+
+// DefaultReminder is how long before the end of a booking the expiring event is sent, for notifications without reminders.
+const DefaultReminder = 20 * time.Minute
+
+// NotificationEvents lists all the booking events that notifications can be sent for.
+var NotificationEvents = []string{
+	NotificationEventScheduled,
+	NotificationEventStarted,
+	NotificationEventExpiring,
+	NotificationEventEnded,
+	NotificationEventStartFailed,
+	NotificationEventCancelled,
+	NotificationEventPromoted,
+	NotificationEventPreempted,
+	NotificationEventUpdated,
+}
+
+// calendarInviteEvents are the events whose emails carry a calendar invite.
+var calendarInviteEvents = []string{NotificationEventScheduled, NotificationEventStarted, NotificationEventPromoted}
+
 type Notification struct {
-	Type      string `json:"type"`
-	Recipient string `json:"recipient"`
+	Type string `json:"type"`
+	// Events are the booking events to notify about. Only the expiring event is notified about when omitted.
+	// +optional
+	Events []string `json:"events,omitempty"`
+	// Reminders are the lead times before the end of the booking to send the expiring event at, like 60m, 15m and 5m.
+	// Defaults to a single reminder 20 minutes before the end.
+	// +optional
+	Reminders []metav1.Duration `json:"reminders,omitempty"`
+	// Recipient is the address of email notifications.
+	// +optional
+	Recipient string `json:"recipient,omitempty"`
+	// SecretRef names a Secret in the namespace of the booking, which holds the settings of the notifier,
+	// like the webhook url of slack, teams and webhook notifications, or the SMTP settings of email notifications.
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secret_ref,omitempty"`
+}
+
+// Wants reports whether the notification should be sent for the event. Email notifications that send calendar invites
+// also send the updated event, so that the calendar entry follows the changes of the booking.
+func (n Notification) Wants(event string) bool {
+	if len(n.Events) == 0 {
+		return event == NotificationEventExpiring
+	}
+
+	if event == NotificationEventUpdated && n.Type == NotificationEmail &&
+		slices.ContainsFunc(n.Events, func(e string) bool { return slices.Contains(calendarInviteEvents, e) }) {
+		return true
+	}
+
+	return slices.Contains(n.Events, event)
+}
+
+// ReminderLeadTimes returns the lead times of the reminders of the notification, from the shortest to the longest.
+func (n Notification) ReminderLeadTimes() []time.Duration {
+	if len(n.Reminders) == 0 {
+		return []time.Duration{DefaultReminder}
+	}
+
+	leadTimes := make([]time.Duration, 0, len(n.Reminders))
+	for _, r := range n.Reminders {
+		leadTimes = append(leadTimes, r.Duration)
+	}
+	slices.Sort(leadTimes)
+
+	return slices.Compact(leadTimes)
+}
+
+// NotificationDelivery records the delivery of an event through one of the notifications of a booking.
+type NotificationDelivery struct {
+	// Index is the position of the notification in spec.notifications.
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	// Reminder is the lead time of the reminder, for the deliveries of the expiring event.
+	// +optional
+	Reminder string `json:"reminder,omitempty"`
+	// EndAt is the end of the booking that the change was made to, for the deliveries of the updated event.
+	// +optional
+	EndAt string `json:"end_at,omitempty"`
+	// SentAt is when the event was delivered, empty until it is.
+	// +optional
+	SentAt string `json:"sent_at,omitempty"`
+	// Attempts counts the delivery attempts. Deliveries are given up after a few failed attempts.
+	// +optional
+	Attempts int `json:"attempts,omitempty"`
+	// LastError is the error of the last failed attempt.
+	// +optional
+	LastError string `json:"last_error,omitempty"`
 }
 
 // BookingSpec defines the desired state of Booking
 type BookingSpec struct {
-	EndAt         string         `json:"end_at"`
-	StartAt       string         `json:"start_at"`
+	EndAt string `json:"end_at"`
+	// StartAt defaults to the time of creation when omitted.
+	// +optional
+	StartAt       string         `json:"start_at,omitempty"`
 	ResourceName  string         `json:"resource_name"`
 	UserID        string         `json:"user_id"`
 	Notifications []Notification `json:"notifications,omitempty"`
+	// ExtendBy requests to move the end of the booking later by the duration. The operator applies it to end_at,
+	// unless the extension overlaps a booking of another user, records the outcome in the history and clears it.
+	// +optional
+	ExtendBy *metav1.Duration `json:"extend_by,omitempty"`
+	// Release requests to end a booking in progress right away, handing the resource back early. The operator sets
+	// end_at to the time of the release, records it in the history and clears it.
+	// +optional
+	Release bool `json:"release,omitempty"`
+	// Queue lets the booking overlap the bookings of other users, instead of being rejected. It waits in the queue of the
+	// resource until the bookings ahead of it end or are released, and is then promoted for what is left of its window.
+	// +optional
+	Queue bool `json:"queue,omitempty"`
+	// Priority lets the booking preempt the overlapping bookings of other users with a lower priority once it starts,
+	// taking over the resource and the lock of its instances. Bookings have the lowest priority, 0, by default.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+}
+
+// BookingHistoryEntry records a change of the window of a booking, like the ones requested through spec.extend_by or
+// spec.release.
+type BookingHistoryEntry struct {
+	// Action is one of extended, extension_rejected, released, promoted and preempted.
+	Action string `json:"action"`
+	At     string `json:"at"`
+	// PreviousEndAt and EndAt are the ends of the booking before and after the change.
+	PreviousEndAt string `json:"previous_end_at"`
+	EndAt         string `json:"end_at"`
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Generation is the generation of the booking that requested the change, if it was requested through the spec.
+	// +optional
+	Generation int64 `json:"generation,omitempty"`
 }
 
 // BookingStatus defines the observed state of Booking
 type BookingStatus struct {
-	Status           string `json:"status,omitempty"`
-	NotificationSent bool   `json:"notification_sent,omitempty"`
+	Status string `json:"status,omitempty"`
+	// Notifications records the delivery of each event through each notification of the booking.
+	// +optional
+	Notifications []NotificationDelivery `json:"notifications,omitempty"`
+	// History records the extensions, releases, promotion and preemption of the booking, from the oldest to the latest.
+	// +optional
+	History []BookingHistoryEntry `json:"history,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,416 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// bookinglog is for logging in this package.
+var bookinglog = logf.Log.WithName("booking-resource")
+
+// BookingWebhookOptions holds the operator wide settings the booking webhooks check against.
+// +kubebuilder:object:generate=false
+type BookingWebhookOptions struct {
+	// MaxDuration is the longest time a single booking can span. Zero means there is no limit.
+	MaxDuration time.Duration
+	// MaxPriority is the highest priority a booking can be given. Zero means that bookings can't preempt others.
+	MaxPriority int32
+}
+
+// bookingDefaulter fills in the optional booking fields on admission.
+// +kubebuilder:object:generate=false
+type bookingDefaulter struct{}
+
+// bookingValidator validates bookings on admission. It uses the client to look up the booked resource and its other bookings.
+// +kubebuilder:object:generate=false
+type bookingValidator struct {
+	Client  client.Client
+	Options BookingWebhookOptions
+}
+
+// SetupWebhookWithManager registers the booking webhooks with the manager.
+func (r *Booking) SetupWebhookWithManager(mgr ctrl.Manager, opts BookingWebhookOptions) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithDefaulter(&bookingDefaulter{}).
+		WithValidator(&bookingValidator{Client: mgr.GetClient(), Options: opts}).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-manager-kotaico-de-v1-booking,mutating=true,failurePolicy=fail,sideEffects=None,groups=manager.kotaico.de,resources=bookings,verbs=create;update,versions=v1,name=mbooking.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomDefaulter = &bookingDefaulter{}
+
+// Default sets the start of the booking to the current time when it is omitted.
+func (d *bookingDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	booking, ok := obj.(*Booking)
+	if !ok {
+		return fmt.Errorf("expected a Booking but got a %T", obj)
+	}
+	bookinglog.Info("default", "name", booking.Name)
+
+	if booking.Spec.StartAt == "" {
+		booking.Spec.StartAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	return nil
+}
+
+//+kubebuilder:webhook:path=/validate-manager-kotaico-de-v1-booking,mutating=false,failurePolicy=fail,sideEffects=None,groups=manager.kotaico.de,resources=bookings,verbs=create;update,versions=v1,name=vbooking.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &bookingValidator{}
+
+// ValidateCreate rejects new bookings with an invalid time window, a priority above the maximum, a missing resource, an overlap
+// with a booking of another user, or that exceed the booking quotas of their user.
+func (v *bookingValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	booking, ok := obj.(*Booking)
+	if !ok {
+		return nil, fmt.Errorf("expected a Booking but got a %T", obj)
+	}
+	bookinglog.Info("validate create", "name", booking.Name)
+
+	return nil, v.validateBooking(ctx, booking, nil)
+}
+
+// ValidateUpdate runs the same checks as ValidateCreate, but only when the spec of the booking changed.
+// Metadata only updates, like the ones done by the operator itself, are always allowed.
+func (v *bookingValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	booking, ok := newObj.(*Booking)
+	if !ok {
+		return nil, fmt.Errorf("expected a Booking but got a %T", newObj)
+	}
+	bookinglog.Info("validate update", "name", booking.Name)
+
+	oldBooking, ok := oldObj.(*Booking)
+	if ok && equality.Semantic.DeepEqual(oldBooking.Spec, booking.Spec) {
+		return nil, nil
+	}
+
+	return nil, v.validateBooking(ctx, booking, oldBooking)
+}
+
+// ValidateDelete allows all deletions.
+func (v *bookingValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateBooking runs all checks on the booking and wraps the failures in a single Invalid error. The old booking is
+// nil on creation.
+func (v *bookingValidator) validateBooking(ctx context.Context, booking, old *Booking) error {
+	allErrs := v.validateWindow(booking)
+	allErrs = append(allErrs, v.validateNotifications(booking)...)
+	if err := v.validatePriority(booking, old); err != nil {
+		allErrs = append(allErrs, err)
+	}
+
+	resource, err := v.validateResource(ctx, booking)
+	if err != nil {
+		allErrs = append(allErrs, err)
+	}
+
+	// Overlaps and quotas only make sense to check for valid windows of existing resources.
+	if len(allErrs) == 0 {
+		if err := v.validateOverlap(ctx, booking); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	if len(allErrs) == 0 {
+		allErrs = append(allErrs, v.validateQuotas(ctx, booking, old, resource)...)
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("Booking").GroupKind(), booking.Name, allErrs)
+}
+
+// validatePriority checks that the priority of the booking doesn't exceed the configured maximum. Bookings that keep or
+// lower their priority stay valid, even if the maximum was lowered since they were created.
+func (v *bookingValidator) validatePriority(booking, old *Booking) *field.Error {
+	priority := booking.Spec.Priority
+	if priority <= v.Options.MaxPriority || (old != nil && priority <= old.Spec.Priority) {
+		return nil
+	}
+
+	return field.Invalid(field.NewPath("spec", "priority"), priority, fmt.Sprintf("must be at most %d", v.Options.MaxPriority))
+}
+
+// validateWindow checks that the booking dates are in RFC3339 format, that it ends after it starts,
+// and that it doesn't last longer than the configured maximum duration.
+func (v *bookingValidator) validateWindow(booking *Booking) field.ErrorList {
+	var allErrs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	start, err := time.Parse(time.RFC3339, booking.Spec.StartAt)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("start_at"), booking.Spec.StartAt, "must be an RFC3339 date time"))
+	}
+
+	end, err := time.Parse(time.RFC3339, booking.Spec.EndAt)
+	if err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("end_at"), booking.Spec.EndAt, "must be an RFC3339 date time"))
+	}
+
+	if len(allErrs) > 0 {
+		return allErrs
+	}
+
+	endPath, endValue := specPath.Child("end_at"), booking.Spec.EndAt
+	if extendBy := booking.Spec.ExtendBy; extendBy != nil {
+		if extendBy.Duration <= 0 {
+			return append(allErrs, field.Invalid(specPath.Child("extend_by"), extendBy.Duration.String(), "must be positive"))
+		}
+		if booking.Spec.Release {
+			return append(allErrs, field.Forbidden(specPath.Child("release"), "a booking can't be extended and released at once"))
+		}
+		// The booking has to stay valid once it is extended
+		end = end.Add(extendBy.Duration)
+		endPath, endValue = specPath.Child("extend_by"), extendBy.Duration.String()
+	}
+
+	if (booking.Spec.ExtendBy != nil || booking.Spec.Release) && booking.Status.Status == BookingFinished {
+		allErrs = append(allErrs, field.Forbidden(specPath, "finished bookings can't be extended or released"))
+	}
+	if (booking.Spec.ExtendBy != nil || booking.Spec.Release) && booking.Status.Status == BookingPreempted {
+		allErrs = append(allErrs, field.Forbidden(specPath, "preempted bookings can't be extended or released"))
+	}
+	if booking.Spec.Release && start.After(time.Now()) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("release"), "only bookings that started can be released, delete the booking instead"))
+	}
+
+	if !end.After(start) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("end_at"), booking.Spec.EndAt, "must be after start_at"))
+	} else if maxDuration := v.Options.MaxDuration; maxDuration > 0 && end.Sub(start) > maxDuration {
+		m := fmt.Sprintf("booking can't last longer than %s", maxDuration)
+		allErrs = append(allErrs, field.Invalid(endPath, endValue, m))
+	}
+
+	return allErrs
+}
+
+// validateNotifications checks that each notification has the settings its type needs.
+func (v *bookingValidator) validateNotifications(booking *Booking) field.ErrorList {
+	var allErrs field.ErrorList
+
+	for i, n := range booking.Spec.Notifications {
+		path := field.NewPath("spec", "notifications").Index(i)
+
+		switch n.Type {
+		case NotificationEmail:
+			if n.Recipient == "" {
+				allErrs = append(allErrs, field.Required(path.Child("recipient"), "email notifications need a recipient"))
+			}
+		case NotificationSlack, NotificationTeams, NotificationWebhook:
+			if n.SecretRef == nil || n.SecretRef.Name == "" {
+				allErrs = append(allErrs, field.Required(path.Child("secret_ref"), n.Type+" notifications need a secret with the webhook url"))
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(path.Child("type"), n.Type,
+				[]string{NotificationEmail, NotificationSlack, NotificationTeams, NotificationWebhook}))
+		}
+
+		for j, event := range n.Events {
+			if !slices.Contains(NotificationEvents, event) {
+				allErrs = append(allErrs, field.NotSupported(path.Child("events").Index(j), event, NotificationEvents))
+			}
+		}
+
+		for j, r := range n.Reminders {
+			if r.Duration <= 0 {
+				allErrs = append(allErrs, field.Invalid(path.Child("reminders").Index(j), r.Duration.String(), "must be positive"))
+			}
+		}
+		if len(n.Reminders) > 0 && !n.Wants(NotificationEventExpiring) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("reminders"), "reminders are only sent for the expiring event"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateResource checks that the booked resource exists in the namespace of the booking, and returns it.
+func (v *bookingValidator) validateResource(ctx context.Context, booking *Booking) (*Resource, *field.Error) {
+	path := field.NewPath("spec", "resource_name")
+	key := types.NamespacedName{Namespace: booking.Namespace, Name: booking.Spec.ResourceName}
+
+	var resource Resource
+	if err := v.Client.Get(ctx, key, &resource); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, field.NotFound(path, booking.Spec.ResourceName)
+		}
+		return nil, field.InternalError(path, err)
+	}
+
+	return &resource, nil
+}
+
+// validateOverlap lists the bookings of the same resource and returns an error naming the first one that conflicts
+// with the given booking. Queued bookings wait for the bookings they overlap instead.
+func (v *bookingValidator) validateOverlap(ctx context.Context, booking *Booking) *field.Error {
+	if booking.Spec.Queue {
+		return nil
+	}
+
+	var bookings BookingList
+	if err := v.Client.List(ctx, &bookings, client.InNamespace(booking.Namespace)); err != nil {
+		return field.InternalError(field.NewPath("spec", "resource_name"), err)
+	}
+
+	for _, other := range bookings.Items {
+		if booking.ConflictsWith(&other) {
+			m := "resource %s is already booked by %s from %s to %s (booking %s)"
+			return field.Forbidden(field.NewPath("spec"), fmt.Sprintf(m, booking.Spec.ResourceName, other.Spec.UserID,
+				other.Spec.StartAt, other.Spec.EndAt, other.Name))
+		}
+	}
+
+	return nil
+}
+
+// validateQuotas checks the booking against the booking quotas in its namespace that apply to its user. Updates are only
+// checked when they book more than the old booking did, so that tightening a quota doesn't keep existing bookings from
+// being updated, like by the operator itself. Kubernetes users that a quota applies to can only book as themselves, so
+// that they can't get around it with the user_id of another user.
+func (v *bookingValidator) validateQuotas(ctx context.Context, booking, old *Booking, resource *Resource) field.ErrorList {
+	if old != nil && !booking.booksMoreThan(old) {
+		return nil
+	}
+
+	var quotas BookingQuotaList
+	if err := v.Client.List(ctx, &quotas, client.InNamespace(booking.Namespace)); err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("spec", "user_id"), err)}
+	}
+
+	var (
+		allErrs  field.ErrorList
+		bookings *BookingList
+	)
+	requester := requestingUser(ctx)
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		if requester != "" && requester != booking.Spec.UserID && quota.AppliesTo(requester) {
+			m := fmt.Sprintf("booking quota %s applies to %s, who can only book with their own user_id", quota.Name, requester)
+			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "user_id"), m))
+		}
+
+		if !quota.AppliesTo(booking.Spec.UserID) {
+			continue
+		}
+
+		if bookings == nil {
+			bookings = &BookingList{}
+			if err := v.Client.List(ctx, bookings, client.InNamespace(booking.Namespace)); err != nil {
+				return field.ErrorList{field.InternalError(field.NewPath("spec", "user_id"), err)}
+			}
+		}
+		allErrs = append(allErrs, quota.admit(booking, resource, bookings.Items)...)
+	}
+
+	return allErrs
+}
+
+// requestingUser returns the name of the Kubernetes user that made the admission request, if the context has one.
+func requestingUser(ctx context.Context) string {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	return req.UserInfo.Username
+}
+
+// booksMoreThan reports whether the booking books another resource or user than the old booking, or a window, including
+// its requested extension, that the old one doesn't cover.
+func (r *Booking) booksMoreThan(old *Booking) bool {
+	if r.Spec.ResourceName != old.Spec.ResourceName || r.Spec.UserID != old.Spec.UserID {
+		return true
+	}
+
+	start, end, err := r.requestedWindow()
+	if err != nil {
+		return true
+	}
+	oldStart, oldEnd, err := old.requestedWindow()
+	if err != nil {
+		return true
+	}
+
+	return start.Before(oldStart) || end.After(oldEnd)
+}
+
+// ConflictsWith reports whether the other booking is a booking of the same resource by another user, which is neither
+// being deleted, finished, preempted nor waiting in the queue, and has a time window intersecting the one of the booking, including its
+// requested extension. Bookings with a lower priority don't conflict, as the booking preempts them.
+func (r *Booking) ConflictsWith(other *Booking) bool {
+	if other.Name == r.Name || other.Spec.ResourceName != r.Spec.ResourceName || other.Spec.UserID == r.Spec.UserID ||
+		other.Status.Status == BookingFinished || other.Status.Status == BookingPreempted || other.Spec.Queue ||
+		other.Spec.Priority < r.Spec.Priority || !other.DeletionTimestamp.IsZero() {
+		return false
+	}
+
+	start, end, err := r.requestedWindow()
+	if err != nil {
+		return false
+	}
+
+	otherStart, otherEnd, err := other.Window()
+	if err != nil {
+		return false
+	}
+
+	return start.Before(otherEnd) && otherStart.Before(end)
+}
+
+// Window returns the parsed start and end time of the booking.
+func (r *Booking) Window() (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, r.Spec.StartAt)
+	if err != nil {
+		return start, time.Time{}, err
+	}
+
+	end, err := time.Parse(time.RFC3339, r.Spec.EndAt)
+	if err != nil {
+		return start, end, err
+	}
+
+	return start, end, nil
+}
+
+// requestedWindow returns the window of the booking once its requested extension is applied.
+func (r *Booking) requestedWindow() (time.Time, time.Time, error) {
+	start, end, err := r.Window()
+	if err == nil && r.Spec.ExtendBy != nil {
+		end = end.Add(r.Spec.ExtendBy.Duration)
+	}
+
+	return start, end, err
+}
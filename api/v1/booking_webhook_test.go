@@ -0,0 +1,529 @@
+package v1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Booking webhook", func() {
+	ctx := context.Background()
+
+	const (
+		BookingNamespace    = "default"
+		BookingResourceName = "ec2.analytics"
+	)
+
+	newBooking := func(name, userID, startAt, endAt string) *Booking {
+		return &Booking{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: BookingNamespace,
+			},
+			Spec: BookingSpec{
+				ResourceName: BookingResourceName,
+				UserID:       userID,
+				StartAt:      startAt,
+				EndAt:        endAt,
+			},
+		}
+	}
+
+	resource := &Resource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BookingResourceName,
+			Namespace: BookingNamespace,
+		},
+		Spec: ResourceSpec{
+			Type: "ec2",
+			Tag:  "analytics",
+		},
+	}
+
+	Context("Defaulting", func() {
+		It("Should set the start to the current time when omitted", func() {
+			booking := newBooking("new", "bob", "", "2030-01-01T13:00:00Z")
+
+			Expect((&bookingDefaulter{}).Default(ctx, booking)).Should(Succeed())
+
+			start, err := time.Parse(time.RFC3339, booking.Spec.StartAt)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(start).Should(BeTemporally("~", time.Now(), time.Minute))
+		})
+
+		It("Should keep an explicit start", func() {
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			Expect((&bookingDefaulter{}).Default(ctx, booking)).Should(Succeed())
+			Expect(booking.Spec.StartAt).Should(Equal("2030-01-01T11:00:00Z"))
+		})
+	})
+
+	Context("Booking fields", func() {
+		It("Should reject dates that are not RFC3339", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource)}
+			booking := newBooking("new", "bob", "2030-01-01 11:00", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.start_at"))
+		})
+
+		It("Should reject bookings that end before they start", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource)}
+			booking := newBooking("new", "bob", "2030-01-01T13:00:00Z", "2030-01-01T11:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("must be after start_at"))
+		})
+
+		It("Should reject bookings longer than the maximum duration", func() {
+			validator := &bookingValidator{
+				Client:  newFakeClient(resource),
+				Options: BookingWebhookOptions{MaxDuration: time.Hour},
+			}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("can't last longer than 1h0m0s"))
+		})
+
+		It("Should reject priorities above the maximum", func() {
+			validator := &bookingValidator{
+				Client:  newFakeClient(resource),
+				Options: BookingWebhookOptions{MaxPriority: 10},
+			}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.Priority = 2147483647
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.priority"))
+
+			booking.Spec.Priority = 10
+			_, err = validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should keep bookings valid that don't raise a priority above the maximum", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource)}
+			old := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			old.Spec.Priority = 10
+			booking := old.DeepCopy()
+			booking.Spec.EndAt = "2030-01-01T14:00:00Z"
+
+			_, err := validator.ValidateUpdate(ctx, old, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			booking.Spec.Priority = 20
+			_, err = validator.ValidateUpdate(ctx, old, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+		})
+
+		It("Should reject bookings of missing resources", func() {
+			validator := &bookingValidator{Client: newFakeClient()}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.resource_name"))
+		})
+
+		It("Should allow metadata updates of existing bookings", func() {
+			validator := &bookingValidator{Client: newFakeClient()}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			updated := booking.DeepCopy()
+			updated.Labels = map[string]string{"team": "analytics"}
+
+			_, err := validator.ValidateUpdate(ctx, booking, updated)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject notifications without the settings of their type", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.Notifications = []Notification{
+				{Type: NotificationEmail},
+				{Type: NotificationSlack},
+				{Type: "pager"},
+			}
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(And(
+				ContainSubstring("spec.notifications[0].recipient"),
+				ContainSubstring("spec.notifications[1].secret_ref"),
+				ContainSubstring("spec.notifications[2].type"),
+			))
+		})
+
+		It("Should reject notifications about unknown events", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.Notifications = []Notification{
+				{Type: NotificationEmail, Recipient: "bob@example.com", Events: []string{NotificationEventStarted, "paused"}},
+			}
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.notifications[0].events[1]"))
+
+			booking.Spec.Notifications[0].Events = NotificationEvents
+			_, err = validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject reminders that aren't positive or aren't sent", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.Notifications = []Notification{
+				{Type: NotificationEmail, Recipient: "bob@example.com", Reminders: []metav1.Duration{{Duration: time.Hour}, {Duration: -time.Minute}}},
+				{Type: NotificationEmail, Recipient: "bob@example.com", Events: []string{NotificationEventEnded}, Reminders: []metav1.Duration{{Duration: time.Hour}}},
+			}
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(And(
+				ContainSubstring("spec.notifications[0].reminders[1]"),
+				ContainSubstring("spec.notifications[1].reminders"),
+			))
+
+			booking.Spec.Notifications = booking.Spec.Notifications[:1]
+			booking.Spec.Notifications[0].Reminders = []metav1.Duration{{Duration: time.Hour}, {Duration: 5 * time.Minute}}
+			_, err = validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("Extending and releasing bookings", func() {
+		var existing *Booking
+
+		BeforeEach(func() {
+			existing = newBooking("existing", "alice", "2030-01-01T13:00:00Z", "2030-01-01T15:00:00Z")
+		})
+
+		extend := func(booking *Booking, d time.Duration) *Booking {
+			updated := booking.DeepCopy()
+			updated.Spec.ExtendBy = &metav1.Duration{Duration: d}
+			return updated
+		}
+
+		It("Should allow extensions up to the next booking of another user", func() {
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T12:00:00Z")
+			validator := &bookingValidator{Client: newFakeClient(resource, existing, booking)}
+
+			_, err := validator.ValidateUpdate(ctx, booking, extend(booking, time.Hour))
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject extensions that overlap a booking of another user", func() {
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T12:00:00Z")
+			validator := &bookingValidator{Client: newFakeClient(resource, existing, booking)}
+
+			_, err := validator.ValidateUpdate(ctx, booking, extend(booking, 90*time.Minute))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("booking existing"))
+		})
+
+		It("Should reject extensions that aren't positive or exceed the maximum duration", func() {
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T12:00:00Z")
+			validator := &bookingValidator{
+				Client:  newFakeClient(resource, booking),
+				Options: BookingWebhookOptions{MaxDuration: 90 * time.Minute},
+			}
+
+			_, err := validator.ValidateUpdate(ctx, booking, extend(booking, -time.Hour))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("spec.extend_by: Invalid value: \"-1h0m0s\": must be positive"))
+
+			_, err = validator.ValidateUpdate(ctx, booking, extend(booking, time.Hour))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("can't last longer than 1h30m0s"))
+		})
+
+		It("Should reject extending and releasing finished bookings", func() {
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T12:00:00Z")
+			booking.Status.Status = BookingFinished
+			validator := &bookingValidator{Client: newFakeClient(resource, booking)}
+
+			_, err := validator.ValidateUpdate(ctx, booking, extend(booking, time.Hour))
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("finished bookings can't be extended or released"))
+		})
+
+		It("Should only allow releasing bookings that started", func() {
+			started := newBooking("started", "bob", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), "2030-01-01T12:00:00Z")
+			scheduled := newBooking("scheduled", "bob", "2029-12-31T11:00:00Z", "2029-12-31T12:00:00Z")
+			validator := &bookingValidator{Client: newFakeClient(resource, started, scheduled)}
+
+			released := started.DeepCopy()
+			released.Spec.Release = true
+			_, err := validator.ValidateUpdate(ctx, started, released)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			released = scheduled.DeepCopy()
+			released.Spec.Release = true
+			_, err = validator.ValidateUpdate(ctx, scheduled, released)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("only bookings that started can be released"))
+		})
+	})
+
+	Context("Overlapping bookings", func() {
+		var existing *Booking
+
+		BeforeEach(func() {
+			existing = newBooking("existing", "alice", "2030-01-01T10:00:00Z", "2030-01-01T12:00:00Z")
+		})
+
+		It("Should reject a booking of another user that overlaps", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("booking existing"))
+		})
+
+		It("Should reject an update that makes the booking overlap", func() {
+			booking := newBooking("new", "bob", "2030-01-01T12:00:00Z", "2030-01-01T13:00:00Z")
+			validator := &bookingValidator{Client: newFakeClient(resource, existing, booking)}
+
+			updated := booking.DeepCopy()
+			updated.Spec.StartAt = "2030-01-01T11:59:00Z"
+
+			_, err := validator.ValidateUpdate(ctx, booking, updated)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+		})
+
+		It("Should allow adjacent bookings", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T12:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should allow overlapping bookings of the same user", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "alice", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should allow overlapping bookings of other resources", func() {
+			reporting := resource.DeepCopy()
+			reporting.Name = "ec2.reporting"
+			validator := &bookingValidator{Client: newFakeClient(resource, reporting, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.ResourceName = reporting.Name
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should ignore finished bookings", func() {
+			existing.Status.Status = BookingFinished
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should allow queued bookings that overlap", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.Queue = true
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should allow bookings with a higher priority that overlap", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, existing), Options: BookingWebhookOptions{MaxPriority: 10}}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+			booking.Spec.Priority = 10
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject bookings with a lower or the same priority that overlap", func() {
+			existing.Spec.Priority = 10
+			validator := &bookingValidator{Client: newFakeClient(resource, existing), Options: BookingWebhookOptions{MaxPriority: 10}}
+
+			for _, priority := range []int32{0, 10} {
+				booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+				booking.Spec.Priority = priority
+
+				_, err := validator.ValidateCreate(ctx, booking)
+				Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			}
+		})
+
+		It("Should ignore preempted bookings", func() {
+			existing.Status.Status = BookingPreempted
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should ignore bookings that are being deleted", func() {
+			existing.Finalizers = []string{"manager.kotaico.de/booking-cleanup"}
+			existing.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should ignore queued bookings", func() {
+			existing.Spec.Queue = true
+			validator := &bookingValidator{Client: newFakeClient(resource, existing)}
+			booking := newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T13:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("Booking quotas", func() {
+		var quota *BookingQuota
+
+		BeforeEach(func() {
+			maxConcurrent, maxHours := int32(1), int32(3)
+			quota = &BookingQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: BookingNamespace},
+				Spec: BookingQuotaSpec{
+					Users:                 []string{"bob", "carol"},
+					MaxConcurrentBookings: &maxConcurrent,
+					MaxHoursPerWeek:       &maxHours,
+					MaxBookingDuration:    &metav1.Duration{Duration: 2 * time.Hour},
+					AllowedResourceTypes:  []string{"ec2"},
+				},
+			}
+		})
+
+		It("Should reject resource types the quota doesn't allow", func() {
+			quota.Spec.AllowedResourceTypes = []string{"rds"}
+			validator := &bookingValidator{Client: newFakeClient(resource, quota)}
+			booking := newBooking("new", "bob", "2030-01-01T10:00:00Z", "2030-01-01T11:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("doesn't allow booking ec2 resources"))
+		})
+
+		It("Should reject bookings longer than the quota allows", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, quota)}
+			booking := newBooking("new", "bob", "2030-01-01T10:00:00Z", "2030-01-01T12:30:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("longer than 2h0m0s"))
+		})
+
+		It("Should reject more concurrent bookings of the users than the quota allows", func() {
+			existing := newBooking("existing", "carol", "2030-01-01T10:00:00Z", "2030-01-01T11:00:00Z")
+			existing.Spec.ResourceName = "ec2.reporting"
+			validator := &bookingValidator{Client: newFakeClient(resource, quota, existing)}
+
+			booking := newBooking("new", "bob", "2030-01-01T10:30:00Z", "2030-01-01T11:30:00Z")
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("allows 1 concurrent bookings"))
+
+			booking = newBooking("new", "bob", "2030-01-01T11:00:00Z", "2030-01-01T12:00:00Z")
+			_, err = validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject bookings that exceed the hours per week of the quota", func() {
+			existing := newBooking("existing", "bob", "2030-01-01T08:00:00Z", "2030-01-01T10:00:00Z")
+			validator := &bookingValidator{Client: newFakeClient(resource, quota, existing)}
+
+			booking := newBooking("new", "carol", "2030-01-04T10:00:00Z", "2030-01-04T12:00:00Z")
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("the week of 2029-12-31 would add up to 4h0m0s"))
+
+			// The week starts on Monday, 2030-01-07
+			booking = newBooking("new", "carol", "2030-01-07T10:00:00Z", "2030-01-07T12:00:00Z")
+			_, err = validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should leave the bookings of other users alone", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, quota)}
+			booking := newBooking("new", "alice", "2030-01-01T10:00:00Z", "2030-01-01T20:00:00Z")
+
+			_, err := validator.ValidateCreate(ctx, booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should only let the users of the quota book as themselves", func() {
+			validator := &bookingValidator{Client: newFakeClient(resource, quota)}
+			asUser := func(username string) context.Context {
+				return admission.NewContextWithRequest(ctx, admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+					UserInfo: authenticationv1.UserInfo{Username: username},
+				}})
+			}
+
+			booking := newBooking("new", "alice", "2030-01-01T10:00:00Z", "2030-01-01T20:00:00Z")
+			_, err := validator.ValidateCreate(asUser("bob"), booking)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+			Expect(err.Error()).Should(ContainSubstring("booking quota team-a applies to bob"))
+
+			By("By leaving users outside of the quota alone")
+			_, err = validator.ValidateCreate(asUser("alice"), booking)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = validator.ValidateCreate(asUser("system:serviceaccount:default:booking-bot"), booking)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should only check updates that book more", func() {
+			booking := newBooking("new", "bob", "2030-01-01T10:00:00Z", "2030-01-01T14:00:00Z")
+			validator := &bookingValidator{Client: newFakeClient(resource, quota, booking)}
+
+			shortened := booking.DeepCopy()
+			shortened.Spec.EndAt = "2030-01-01T13:00:00Z"
+			_, err := validator.ValidateUpdate(ctx, booking, shortened)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			extended := booking.DeepCopy()
+			extended.Spec.ExtendBy = &metav1.Duration{Duration: time.Hour}
+			_, err = validator.ValidateUpdate(ctx, booking, extended)
+			Expect(apierrors.IsInvalid(err)).Should(BeTrue())
+		})
+
+		It("Should report the usage of the quota", func() {
+			running := newBooking("running", "bob", "2030-01-01T10:00:00Z", "2030-01-01T12:00:00Z")
+			scheduled := newBooking("scheduled", "carol", "2030-01-06T22:00:00Z", "2030-01-07T02:00:00Z")
+			preempted := newBooking("preempted", "carol", "2030-01-01T10:00:00Z", "2030-01-01T12:00:00Z")
+			preempted.Status.Status = BookingPreempted
+			other := newBooking("other", "alice", "2030-01-01T10:00:00Z", "2030-01-01T12:00:00Z")
+			now, _ := time.Parse(time.RFC3339, "2030-01-01T11:00:00Z")
+
+			usage := quota.Usage([]Booking{*running, *scheduled, *preempted, *other}, now)
+			Expect(usage.ConcurrentBookings).Should(Equal(int32(1)))
+			Expect(usage.WeekStart).Should(Equal("2029-12-31T00:00:00Z"))
+			Expect(usage.BookedThisWeek.Duration).Should(Equal(4 * time.Hour))
+		})
+	})
+})
@@ -0,0 +1,239 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// BookingQuotaSpec defines the desired state of BookingQuota
+type BookingQuotaSpec struct {
+	// Users are the user_id of the bookings the quota applies to. The limits apply to the bookings of all the users
+	// together, so a quota of a single user limits that user, and a quota of the members of a team limits the team.
+	// The user_id of a booking is free-form, so the users are also matched against the name of the Kubernetes user that
+	// creates or changes a booking, who can then only book with their own name as user_id. Users that book through
+	// another client, like a service account, are only limited by the user_id it sets.
+	// +kubebuilder:validation:MinItems=1
+	Users []string `json:"users"`
+	// MaxConcurrentBookings is how many bookings of the users can overlap at any time.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxConcurrentBookings *int32 `json:"max_concurrent_bookings,omitempty"`
+	// MaxHoursPerWeek is how many hours the bookings of the users can add up to in a week, from Monday to Sunday in UTC.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxHoursPerWeek *int32 `json:"max_hours_per_week,omitempty"`
+	// MaxBookingDuration is the longest a single booking of the users can last, like 8h.
+	// +optional
+	MaxBookingDuration *metav1.Duration `json:"max_booking_duration,omitempty"`
+	// AllowedResourceTypes are the types of the resources the users can book, like ec2 and rds. All types are allowed
+	// when omitted.
+	// +optional
+	AllowedResourceTypes []string `json:"allowed_resource_types,omitempty"`
+}
+
+// BookingQuotaStatus defines the observed state of BookingQuota
+type BookingQuotaStatus struct {
+	// ConcurrentBookings is how many bookings of the users are in progress.
+	ConcurrentBookings int32 `json:"concurrent_bookings"`
+	// WeekStart is the start of the current week, which the booked time is counted over.
+	WeekStart string `json:"week_start,omitempty"`
+	// BookedThisWeek is how long the bookings of the users last in the current week, scheduled bookings included.
+	BookedThisWeek metav1.Duration `json:"booked_this_week"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:JSONPath=".status.concurrent_bookings",name="CONCURRENT",type="integer"
+//+kubebuilder:printcolumn:JSONPath=".spec.max_concurrent_bookings",name="MAX CONCURRENT",type="integer"
+//+kubebuilder:printcolumn:JSONPath=".status.booked_this_week",name="BOOKED THIS WEEK",type="string"
+//+kubebuilder:printcolumn:JSONPath=".spec.max_hours_per_week",name="MAX HOURS PER WEEK",type="integer"
+
+// BookingQuota is the Schema for the bookingquotas API
+type BookingQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BookingQuotaSpec   `json:"spec,omitempty"`
+	Status BookingQuotaStatus `json:"status,omitempty"`
+}
+
+// AppliesTo reports whether the quota limits the bookings of the user.
+func (q *BookingQuota) AppliesTo(userID string) bool {
+	return slices.Contains(q.Spec.Users, userID)
+}
+
+// Usage returns the usage of the quota at the time, given the bookings in its namespace.
+func (q *BookingQuota) Usage(bookings []Booking, now time.Time) BookingQuotaStatus {
+	windows := q.windows(bookings, "")
+	week := WeekStart(now)
+
+	status := BookingQuotaStatus{
+		WeekStart:      week.Format(time.RFC3339),
+		BookedThisWeek: metav1.Duration{Duration: bookedBetween(windows, week, week.AddDate(0, 0, 7))},
+	}
+	for _, w := range windows {
+		if !now.Before(w.start) && now.Before(w.end) {
+			status.ConcurrentBookings++
+		}
+	}
+
+	return status
+}
+
+// admit checks the booking of a resource against the limits of the quota, given the bookings in its namespace.
+func (q *BookingQuota) admit(booking *Booking, resource *Resource, bookings []Booking) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if len(q.Spec.AllowedResourceTypes) > 0 && !slices.Contains(q.Spec.AllowedResourceTypes, resource.Spec.Type) {
+		m := fmt.Sprintf("booking quota %s doesn't allow booking %s resources", q.Name, resource.Spec.Type)
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("resource_name"), m))
+	}
+
+	start, end, err := booking.requestedWindow()
+	if err != nil {
+		return allErrs
+	}
+
+	if maxDuration := q.Spec.MaxBookingDuration; maxDuration != nil && end.Sub(start) > maxDuration.Duration {
+		m := fmt.Sprintf("booking quota %s doesn't allow bookings longer than %s", q.Name, maxDuration.Duration)
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("end_at"), m))
+	}
+
+	windows := append(q.windows(bookings, booking.Name), timeWindow{start, end})
+
+	if maxConcurrent := q.Spec.MaxConcurrentBookings; maxConcurrent != nil {
+		if peak := peakConcurrency(windows, start, end); peak > int(*maxConcurrent) {
+			m := fmt.Sprintf("booking quota %s allows %d concurrent bookings, the booking would make %d", q.Name, *maxConcurrent, peak)
+			allErrs = append(allErrs, field.Forbidden(specPath, m))
+		}
+	}
+
+	if maxHours := q.Spec.MaxHoursPerWeek; maxHours != nil {
+		limit := time.Duration(*maxHours) * time.Hour
+		for week := WeekStart(start); week.Before(end); week = week.AddDate(0, 0, 7) {
+			if booked := bookedBetween(windows, week, week.AddDate(0, 0, 7)); booked > limit {
+				m := fmt.Sprintf("booking quota %s allows %d hours of bookings per week, the week of %s would add up to %s",
+					q.Name, *maxHours, week.Format(time.DateOnly), booked)
+				allErrs = append(allErrs, field.Forbidden(specPath, m))
+				break
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// timeWindow is the time a booking lasts.
+type timeWindow struct {
+	start, end time.Time
+}
+
+// windows returns the windows of the bookings that count towards the quota, leaving out the named booking.
+// Preempted bookings and bookings being deleted don't count.
+func (q *BookingQuota) windows(bookings []Booking, leaveOut string) []timeWindow {
+	var windows []timeWindow
+	for i := range bookings {
+		booking := &bookings[i]
+		if booking.Name == leaveOut || !q.AppliesTo(booking.Spec.UserID) || booking.Status.Status == BookingPreempted ||
+			!booking.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if start, end, err := booking.Window(); err == nil {
+			windows = append(windows, timeWindow{start, end})
+		}
+	}
+
+	return windows
+}
+
+// WeekStart returns the start of the week of the time, which is Monday at midnight in UTC.
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// bookedBetween returns how long the windows last between from and to.
+func bookedBetween(windows []timeWindow, from, to time.Time) time.Duration {
+	var booked time.Duration
+	for _, w := range windows {
+		start, end := w.start, w.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			booked += end.Sub(start)
+		}
+	}
+
+	return booked
+}
+
+// peakConcurrency returns the most windows that overlap at once between from and to.
+func peakConcurrency(windows []timeWindow, from, to time.Time) int {
+	type change struct {
+		at    time.Time
+		delta int
+	}
+
+	var changes []change
+	for _, w := range windows {
+		if w.start.Before(to) && from.Before(w.end) {
+			changes = append(changes, change{w.start, 1}, change{w.end, -1})
+		}
+	}
+	// Windows that end when others start don't overlap them
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].at.Equal(changes[j].at) {
+			return changes[i].delta < changes[j].delta
+		}
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	peak, current := 0, 0
+	for _, c := range changes {
+		current += c.delta
+		peak = max(peak, current)
+	}
+
+	return peak
+}
+
+//+kubebuilder:object:root=true
+
+// BookingQuotaList contains a list of BookingQuota
+type BookingQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BookingQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BookingQuota{}, &BookingQuotaList{})
+}
@@ -24,6 +24,10 @@ import (
 type ResourceSpec struct {
 	BookedBy    string `json:"booked_by"`
 	BookedUntil string `json:"booked_until"`
+	// PreemptedBy is the user of the booking that booked_by preempted. The instances are started as booked_by even
+	// while they are still locked by that user, which hands their lock over.
+	// +optional
+	PreemptedBy string `json:"preempted_by,omitempty"`
 
 	Tag  string `json:"tag"`
 	Type string `json:"type"`
@@ -36,6 +40,18 @@ type ResourceStatus struct {
 	Status      string `json:"status"`
 	LockedBy    string `json:"locked_by"`
 	LockedUntil string `json:"locked_until"`
+
+	// AutoRestarts counts the instances that the cloud provider started on its own while the resource wasn't booked,
+	// and that were stopped again.
+	// +optional
+	AutoRestarts int `json:"auto_restarts,omitempty"`
+	// LastAutoRestart is the time the last auto-started instance was stopped again.
+	// +optional
+	LastAutoRestart string `json:"last_auto_restart,omitempty"`
+	// StartError is the error of the last failed attempt to start the instances of the booked resource.
+	// It is cleared once they start, or the resource is no longer booked.
+	// +optional
+	StartError string `json:"start_error,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -21,6 +21,8 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -30,7 +32,7 @@ func (in *Booking) DeepCopyInto(out *Booking) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Booking.
@@ -51,6 +53,21 @@ func (in *Booking) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BookingHistoryEntry) DeepCopyInto(out *BookingHistoryEntry) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookingHistoryEntry.
+func (in *BookingHistoryEntry) DeepCopy() *BookingHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(BookingHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BookingList) DeepCopyInto(out *BookingList) {
 	*out = *in
@@ -83,6 +100,121 @@ func (in *BookingList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BookingQuota) DeepCopyInto(out *BookingQuota) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookingQuota.
+func (in *BookingQuota) DeepCopy() *BookingQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(BookingQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BookingQuota) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BookingQuotaList) DeepCopyInto(out *BookingQuotaList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]BookingQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookingQuotaList.
+func (in *BookingQuotaList) DeepCopy() *BookingQuotaList {
+	if in == nil {
+		return nil
+	}
+	out := new(BookingQuotaList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *BookingQuotaList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BookingQuotaSpec) DeepCopyInto(out *BookingQuotaSpec) {
+	*out = *in
+	if in.Users != nil {
+		in, out := &in.Users, &out.Users
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxConcurrentBookings != nil {
+		in, out := &in.MaxConcurrentBookings, &out.MaxConcurrentBookings
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxHoursPerWeek != nil {
+		in, out := &in.MaxHoursPerWeek, &out.MaxHoursPerWeek
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxBookingDuration != nil {
+		in, out := &in.MaxBookingDuration, &out.MaxBookingDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.AllowedResourceTypes != nil {
+		in, out := &in.AllowedResourceTypes, &out.AllowedResourceTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookingQuotaSpec.
+func (in *BookingQuotaSpec) DeepCopy() *BookingQuotaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BookingQuotaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BookingQuotaStatus) DeepCopyInto(out *BookingQuotaStatus) {
+	*out = *in
+	out.BookedThisWeek = in.BookedThisWeek
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookingQuotaStatus.
+func (in *BookingQuotaStatus) DeepCopy() *BookingQuotaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BookingQuotaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BookingScheduler) DeepCopyInto(out *BookingScheduler) {
 	*out = *in
@@ -179,7 +311,14 @@ func (in *BookingSpec) DeepCopyInto(out *BookingSpec) {
 	if in.Notifications != nil {
 		in, out := &in.Notifications, &out.Notifications
 		*out = make([]Notification, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtendBy != nil {
+		in, out := &in.ExtendBy, &out.ExtendBy
+		*out = new(metav1.Duration)
+		**out = **in
 	}
 }
 
@@ -196,6 +335,16 @@ func (in *BookingSpec) DeepCopy() *BookingSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BookingStatus) DeepCopyInto(out *BookingStatus) {
 	*out = *in
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = make([]NotificationDelivery, len(*in))
+		copy(*out, *in)
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]BookingHistoryEntry, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BookingStatus.
@@ -211,6 +360,21 @@ func (in *BookingStatus) DeepCopy() *BookingStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Notification) DeepCopyInto(out *Notification) {
 	*out = *in
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Reminders != nil {
+		in, out := &in.Reminders, &out.Reminders
+		*out = make([]metav1.Duration, len(*in))
+		copy(*out, *in)
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Notification.
@@ -223,6 +387,21 @@ func (in *Notification) DeepCopy() *Notification {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationDelivery) DeepCopyInto(out *NotificationDelivery) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationDelivery.
+func (in *NotificationDelivery) DeepCopy() *NotificationDelivery {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationDelivery)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Resource) DeepCopyInto(out *Resource) {
 	*out = *in
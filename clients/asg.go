@@ -0,0 +1,282 @@
+package clients
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+)
+
+const (
+	// Tags that store the sizes a group had before it was scaled to zero
+	minSizeTag         string = "resource-booking-min-size"
+	maxSizeTag         string = "resource-booking-max-size"
+	desiredCapacityTag string = "resource-booking-desired-capacity"
+
+	asgResourceType string = "auto-scaling-group"
+)
+
+// ASGAPI is the part of the Auto Scaling client that the Auto Scaling group resources and monitors use.
+type ASGAPI interface {
+	DescribeAutoScalingGroups(ctx context.Context, params *autoscaling.DescribeAutoScalingGroupsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeAutoScalingGroupsOutput, error)
+	UpdateAutoScalingGroup(ctx context.Context, params *autoscaling.UpdateAutoScalingGroupInput, optFns ...func(*autoscaling.Options)) (*autoscaling.UpdateAutoScalingGroupOutput, error)
+	CreateOrUpdateTags(ctx context.Context, params *autoscaling.CreateOrUpdateTagsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.CreateOrUpdateTagsOutput, error)
+	DeleteTags(ctx context.Context, params *autoscaling.DeleteTagsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DeleteTagsOutput, error)
+}
+
+// ASGResource represents a collection of EC2 Auto Scaling groups grouped by a common "resource-booking-application" tag.
+// Unlike the EC2 resource, it scales the groups instead of stopping their instances, which the groups would replace.
+type ASGResource struct {
+	NameTag string
+	Client  ASGAPI
+}
+
+type ASGMonitor struct {
+	Type   string
+	Client ASGAPI
+}
+
+type asgGroupDetails struct {
+	Groups []types.AutoScalingGroup
+	Tags   map[string]string
+}
+
+var asgCtx = context.Background()
+
+// Start restores the sizes the resource groups had before they were stopped, and locks them with tags.
+func (r *ASGResource) Start(startInput ResourceStartInput) error {
+	groups, err := r.getGroupDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(startInput.UID, groups.Tags, startInput.Preempts); err != nil {
+		return err
+	}
+
+	for _, group := range groups.Groups {
+		if sizes, ok, err := asgRememberedSizes(group.Tags); err != nil {
+			return err
+		} else if ok {
+			_, err = r.Client.UpdateAutoScalingGroup(asgCtx, &autoscaling.UpdateAutoScalingGroupInput{
+				AutoScalingGroupName: group.AutoScalingGroupName,
+				MinSize:              aws.Int32(sizes[minSizeTag]),
+				MaxSize:              aws.Int32(sizes[maxSizeTag]),
+				DesiredCapacity:      aws.Int32(sizes[desiredCapacityTag]),
+			})
+			if err != nil {
+				return err
+			}
+
+			err = asgDeleteTags(r.Client, *group.AutoScalingGroupName, minSizeTag, maxSizeTag, desiredCapacityTag)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = asgSetTags(r.Client, *group.AutoScalingGroupName, map[string]string{
+			lockedByTag:    startInput.UID,
+			lockedUntilTag: startInput.EndAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop scales the resource groups to zero, persisting their sizes in tags, and removes their lock tags.
+func (r *ASGResource) Stop(stopInput ResourceStopInput) error {
+	groups, err := r.getGroupDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(stopInput.UID, groups.Tags, ""); err != nil {
+		return err
+	}
+
+	for _, group := range groups.Groups {
+		// Don't overwrite the sizes of a group that is already scaled to zero
+		if aws.ToInt32(group.MaxSize) > 0 {
+			err = asgSetTags(r.Client, *group.AutoScalingGroupName, map[string]string{
+				minSizeTag:         strconv.Itoa(int(aws.ToInt32(group.MinSize))),
+				maxSizeTag:         strconv.Itoa(int(aws.ToInt32(group.MaxSize))),
+				desiredCapacityTag: strconv.Itoa(int(aws.ToInt32(group.DesiredCapacity))),
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = r.Client.UpdateAutoScalingGroup(asgCtx, &autoscaling.UpdateAutoScalingGroupInput{
+			AutoScalingGroupName: group.AutoScalingGroupName,
+			MinSize:              aws.Int32(0),
+			MaxSize:              aws.Int32(0),
+			DesiredCapacity:      aws.Int32(0),
+		})
+		if err != nil {
+			return err
+		}
+
+		err = asgDeleteTags(r.Client, *group.AutoScalingGroupName, lockedByTag, lockedUntilTag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status returns the in service instances of the resource groups as running, and their desired capacity as available.
+// The desired capacity of a stopped group is the one it will be restored to on start.
+func (r *ASGResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	groups, err := r.getGroupDetails(r.NameTag)
+	if err != nil {
+		return rst, err
+	}
+
+	for _, group := range groups.Groups {
+		desired := aws.ToInt32(group.DesiredCapacity)
+		if sizes, ok, err := asgRememberedSizes(group.Tags); err != nil {
+			return rst, err
+		} else if ok && desired == 0 {
+			desired = sizes[desiredCapacityTag]
+		}
+		rst.Available += int(desired)
+
+		for _, inst := range group.Instances {
+			if inst.LifecycleState == types.LifecycleStateInService {
+				rst.Running++
+			}
+		}
+	}
+
+	rst.LockedBy, rst.LockedUntil = groups.Tags[lockedByTag], groups.Tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// getGroupDetails returns the groups tagged with the given name tag, along with their lock tags.
+func (r *ASGResource) getGroupDetails(nameTag string) (asgGroupDetails, error) {
+	details := asgGroupDetails{Tags: make(map[string]string)}
+
+	groups, err := listAutoScalingGroups(r.Client, defaultTagKey, nameTag)
+	if err != nil {
+		return details, err
+	}
+	details.Groups = groups
+
+	// The groups of a resource are locked together, so the tags of any of them are the lock of the resource
+	for _, group := range groups {
+		for _, t := range group.Tags {
+			if *t.Key == lockedByTag || *t.Key == lockedUntilTag {
+				details.Tags[*t.Key] = aws.ToString(t.Value)
+			}
+		}
+
+		if len(details.Tags) == 2 {
+			break
+		}
+	}
+
+	return details, nil
+}
+
+// GetNewResources compares the local cluster resources with the ones returned from Auto Scaling
+// and gives back a list of resources that need to be created on the cluster.
+func (m *ASGMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags := make(map[string]bool)
+
+	groups, err := listAutoScalingGroups(m.Client, resourceMonitorTagKey, "true")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, group := range groups {
+		for _, t := range group.Tags {
+			if *t.Key == defaultTagKey {
+				uniqueTags[aws.ToString(t.Value)] = true
+			}
+		}
+	}
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// listAutoScalingGroups returns the groups that have the given tag set to the given value.
+func listAutoScalingGroups(asgClient ASGAPI, tagKey, tagValue string) ([]types.AutoScalingGroup, error) {
+	var groups []types.AutoScalingGroup
+
+	tagFilter := "tag:" + tagKey
+	pages := autoscaling.NewDescribeAutoScalingGroupsPaginator(asgClient, &autoscaling.DescribeAutoScalingGroupsInput{
+		Filters: []types.Filter{{Name: &tagFilter, Values: []string{tagValue}}},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(asgCtx)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page.AutoScalingGroups...)
+	}
+
+	return groups, nil
+}
+
+// asgRememberedSizes returns the sizes persisted in the group tags on stop, and whether they were found.
+func asgRememberedSizes(tags []types.TagDescription) (map[string]int32, bool, error) {
+	sizes := make(map[string]int32)
+
+	for _, t := range tags {
+		switch *t.Key {
+		case minSizeTag, maxSizeTag, desiredCapacityTag:
+			size, err := strconv.ParseInt(aws.ToString(t.Value), 10, 32)
+			if err != nil {
+				return nil, false, err
+			}
+			sizes[*t.Key] = int32(size)
+		}
+	}
+
+	return sizes, len(sizes) == 3, nil
+}
+
+// asgSetTags creates or updates the given tags on the group. They are not propagated to the instances the group launches.
+func asgSetTags(asgClient ASGAPI, groupName string, tags map[string]string) error {
+	var asgTags []types.Tag
+	for k, v := range tags {
+		asgTags = append(asgTags, types.Tag{
+			Key:               aws.String(k),
+			Value:             aws.String(v),
+			ResourceId:        aws.String(groupName),
+			ResourceType:      aws.String(asgResourceType),
+			PropagateAtLaunch: aws.Bool(false),
+		})
+	}
+
+	_, err := asgClient.CreateOrUpdateTags(asgCtx, &autoscaling.CreateOrUpdateTagsInput{Tags: asgTags})
+	return err
+}
+
+// asgDeleteTags removes the tags with the given keys from the group.
+func asgDeleteTags(asgClient ASGAPI, groupName string, keys ...string) error {
+	var asgTags []types.Tag
+	for _, k := range keys {
+		asgTags = append(asgTags, types.Tag{
+			Key:          aws.String(k),
+			ResourceId:   aws.String(groupName),
+			ResourceType: aws.String(asgResourceType),
+		})
+	}
+
+	_, err := asgClient.DeleteTags(asgCtx, &autoscaling.DeleteTagsInput{Tags: asgTags})
+	return err
+}
@@ -0,0 +1,236 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+)
+
+// fakeASG keeps the groups in memory and implements the Auto Scaling calls the ASG backend makes.
+// Groups have as many in service instances as their desired capacity as soon as it is updated.
+type fakeASG struct {
+	mu     sync.Mutex
+	groups map[string]*types.AutoScalingGroup
+}
+
+func newFakeASG(groups ...types.AutoScalingGroup) *fakeASG {
+	f := &fakeASG{groups: make(map[string]*types.AutoScalingGroup)}
+	for i := range groups {
+		f.groups[*groups[i].AutoScalingGroupName] = &groups[i]
+	}
+	return f
+}
+
+func (f *fakeASG) DescribeAutoScalingGroups(ctx context.Context, params *autoscaling.DescribeAutoScalingGroupsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out autoscaling.DescribeAutoScalingGroupsOutput
+	for _, group := range f.groups {
+		if fakeASGMatches(group, params.Filters) {
+			g := *group
+			g.Tags = append([]types.TagDescription(nil), group.Tags...)
+			out.AutoScalingGroups = append(out.AutoScalingGroups, g)
+		}
+	}
+	return &out, nil
+}
+
+func (f *fakeASG) UpdateAutoScalingGroup(ctx context.Context, params *autoscaling.UpdateAutoScalingGroupInput, optFns ...func(*autoscaling.Options)) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	group := f.groups[*params.AutoScalingGroupName]
+	group.MinSize, group.MaxSize, group.DesiredCapacity = params.MinSize, params.MaxSize, params.DesiredCapacity
+	group.Instances = fakeASGInstances(aws.ToInt32(params.DesiredCapacity))
+	return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (f *fakeASG) CreateOrUpdateTags(ctx context.Context, params *autoscaling.CreateOrUpdateTagsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.CreateOrUpdateTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tag := range params.Tags {
+		group := f.groups[*tag.ResourceId]
+		group.Tags = fakeASGDeleteTag(group.Tags, *tag.Key)
+		group.Tags = append(group.Tags, types.TagDescription{Key: tag.Key, Value: tag.Value, ResourceId: tag.ResourceId})
+	}
+	return &autoscaling.CreateOrUpdateTagsOutput{}, nil
+}
+
+func (f *fakeASG) DeleteTags(ctx context.Context, params *autoscaling.DeleteTagsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DeleteTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tag := range params.Tags {
+		group := f.groups[*tag.ResourceId]
+		group.Tags = fakeASGDeleteTag(group.Tags, *tag.Key)
+	}
+	return &autoscaling.DeleteTagsOutput{}, nil
+}
+
+func fakeASGMatches(group *types.AutoScalingGroup, filters []types.Filter) bool {
+	for _, filter := range filters {
+		v, ok := fakeASGTag(group, (*filter.Name)[len("tag:"):])
+		if !ok || len(filter.Values) == 0 || v != filter.Values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func fakeASGDeleteTag(tags []types.TagDescription, key string) []types.TagDescription {
+	var kept []types.TagDescription
+	for _, t := range tags {
+		if *t.Key != key {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func fakeASGTag(group *types.AutoScalingGroup, key string) (string, bool) {
+	for _, t := range group.Tags {
+		if *t.Key == key {
+			return aws.ToString(t.Value), true
+		}
+	}
+	return "", false
+}
+
+func fakeASGInstances(n int32) []types.Instance {
+	instances := make([]types.Instance, n)
+	for i := range instances {
+		instances[i].LifecycleState = types.LifecycleStateInService
+	}
+	return instances
+}
+
+func newAutoScalingGroup(name string, minSize, maxSize, desired int32, tags map[string]string) types.AutoScalingGroup {
+	group := types.AutoScalingGroup{
+		AutoScalingGroupName: aws.String(name),
+		MinSize:              aws.Int32(minSize),
+		MaxSize:              aws.Int32(maxSize),
+		DesiredCapacity:      aws.Int32(desired),
+		Instances:            fakeASGInstances(desired),
+	}
+	for k, v := range tags {
+		group.Tags = append(group.Tags, types.TagDescription{Key: aws.String(k), Value: aws.String(v), ResourceId: aws.String(name)})
+	}
+	return group
+}
+
+func TestASGResourceStartStop(t *testing.T) {
+	fake := newFakeASG(
+		newAutoScalingGroup("analytics-web", 1, 4, 2, map[string]string{defaultTagKey: "analytics"}),
+		newAutoScalingGroup("analytics-batch", 0, 3, 1, map[string]string{defaultTagKey: "analytics"}),
+		newAutoScalingGroup("reporting-web", 1, 2, 1, map[string]string{defaultTagKey: "reporting"}),
+	)
+	resource := &ASGResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 3}
+	if rst != want {
+		t.Errorf("Status() of the stopped resource = %+v, want %+v", rst, want)
+	}
+	web := fake.groups["analytics-web"]
+	if aws.ToInt32(web.MinSize) != 0 || aws.ToInt32(web.MaxSize) != 0 || aws.ToInt32(web.DesiredCapacity) != 0 {
+		t.Errorf("sizes after Stop() = %d/%d/%d, want 0/0/0", aws.ToInt32(web.MinSize), aws.ToInt32(web.MaxSize), aws.ToInt32(web.DesiredCapacity))
+	}
+	if got := aws.ToInt32(fake.groups["reporting-web"].DesiredCapacity); got != 1 {
+		t.Errorf("group of another resource has desired capacity %d, want 1", got)
+	}
+
+	// Stopping a stopped group again must not overwrite the sizes it remembers with zeros
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("second Stop() error = %v", err)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 3, Running: 3, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() of the started resource = %+v, want %+v", rst, want)
+	}
+	if aws.ToInt32(web.MinSize) != 1 || aws.ToInt32(web.MaxSize) != 4 || aws.ToInt32(web.DesiredCapacity) != 2 {
+		t.Errorf("sizes after Start() = %d/%d/%d, want 1/4/2", aws.ToInt32(web.MinSize), aws.ToInt32(web.MaxSize), aws.ToInt32(web.DesiredCapacity))
+	}
+	for _, key := range []string{minSizeTag, maxSizeTag, desiredCapacityTag} {
+		if _, ok := fakeASGTag(web, key); ok {
+			t.Errorf("%s tag is left after Start()", key)
+		}
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	for _, key := range []string{lockedByTag, lockedUntilTag} {
+		if _, ok := fakeASGTag(web, key); ok {
+			t.Errorf("%s tag is left after Stop()", key)
+		}
+	}
+}
+
+func TestASGResourceLock(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	fake := newFakeASG(
+		newAutoScalingGroup("analytics-web", 1, 4, 2, map[string]string{defaultTagKey: "analytics", lockedByTag: "alice", lockedUntilTag: lockedUntil}),
+	)
+	resource := &ASGResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	var lockedErr *LockedError
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt}); !errors.As(err, &lockedErr) || lockedErr.LockedBy != "alice" {
+		t.Errorf("Start() by another user error = %v, want a lock of alice", err)
+	}
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); !errors.As(err, &lockedErr) {
+		t.Errorf("Stop() by another user error = %v, want a LockedError", err)
+	}
+	if got := aws.ToInt32(fake.groups["analytics-web"].DesiredCapacity); got != 2 {
+		t.Errorf("desired capacity of the locked group = %d, want 2", got)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt, Preempts: "alice"}); err != nil {
+		t.Fatalf("Start() preempting the lock holder error = %v", err)
+	}
+	if lockedBy, _ := fakeASGTag(fake.groups["analytics-web"], lockedByTag); lockedBy != "bob" {
+		t.Errorf("%s after the preemption = %q, want bob", lockedByTag, lockedBy)
+	}
+}
+
+func TestASGMonitorGetNewResources(t *testing.T) {
+	fake := newFakeASG(
+		newAutoScalingGroup("analytics-web", 1, 1, 1, map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"}),
+		newAutoScalingGroup("reporting-web", 1, 1, 1, map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"}),
+		newAutoScalingGroup("unmanaged-web", 1, 1, 1, map[string]string{defaultTagKey: "unmanaged"}),
+	)
+	monitor := &ASGMonitor{Type: TypeASG, Client: fake}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
@@ -0,0 +1,268 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+)
+
+const azurePowerStateRunning = "PowerState/running"
+
+// AzureVMResource represents a collection of Azure virtual machines grouped by a common "resource-booking-application" tag.
+type AzureVMResource struct {
+	NameTag string
+	Client  *armcompute.VirtualMachinesClient
+}
+
+type AzureVMMonitor struct {
+	Type   string
+	Client *armcompute.VirtualMachinesClient
+}
+
+type azureVMDetails struct {
+	VMs  []*armcompute.VirtualMachine
+	Tags map[string]string
+}
+
+var azureCtx = context.Background()
+
+// NewAzureVMClient creates a virtual machines client for the given subscription, authenticated with the default Azure credential chain.
+func NewAzureVMClient(subscriptionID string) (*armcompute.VirtualMachinesClient, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load Azure credentials: %w", err)
+	}
+
+	client, err := armcompute.NewVirtualMachinesClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Azure virtual machines client: %w", err)
+	}
+
+	return client, nil
+}
+
+// Start locks the virtual machines of the resource with tags and starts them.
+// The tags are written first, as Azure rejects updates of a machine while it is starting.
+func (r *AzureVMResource) Start(startInput ResourceStartInput) error {
+	vms, err := r.getVMDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(startInput.UID, vms.Tags, startInput.Preempts); err != nil {
+		return err
+	}
+
+	err = r.setTags(vms.VMs, map[string]string{lockedByTag: startInput.UID, lockedUntilTag: startInput.EndAt})
+	if err != nil {
+		return err
+	}
+
+	for _, vm := range vms.VMs {
+		rg, err := azureResourceGroup(vm)
+		if err != nil {
+			return err
+		}
+
+		if _, err = r.Client.BeginStart(azureCtx, rg, *vm.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop removes the lock tags of the resource virtual machines and deallocates them, so that they are no longer billed.
+func (r *AzureVMResource) Stop(stopInput ResourceStopInput) error {
+	vms, err := r.getVMDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(stopInput.UID, vms.Tags, ""); err != nil {
+		return err
+	}
+
+	err = r.setTags(vms.VMs, map[string]string{lockedByTag: "", lockedUntilTag: ""})
+	if err != nil {
+		return err
+	}
+
+	for _, vm := range vms.VMs {
+		rg, err := azureResourceGroup(vm)
+		if err != nil {
+			return err
+		}
+
+		if _, err = r.Client.BeginDeallocate(azureCtx, rg, *vm.Name, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status returns the current summary of the resource virtual machines, based on their power state.
+func (r *AzureVMResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	vms, err := r.getVMDetails(r.NameTag)
+	if err != nil {
+		return rst, err
+	}
+
+	for _, vm := range vms.VMs {
+		rg, err := azureResourceGroup(vm)
+		if err != nil {
+			return rst, err
+		}
+
+		resp, err := r.Client.InstanceView(azureCtx, rg, *vm.Name, nil)
+		if err != nil {
+			return rst, err
+		}
+
+		rst.Available++
+		for _, status := range resp.Statuses {
+			if status.Code != nil && *status.Code == azurePowerStateRunning {
+				rst.Running++
+				break
+			}
+		}
+	}
+
+	rst.LockedBy, rst.LockedUntil = vms.Tags[lockedByTag], vms.Tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// setTags merges the given tags into the tags of each virtual machine. Tags with an empty value are removed.
+func (r *AzureVMResource) setTags(vms []*armcompute.VirtualMachine, tags map[string]string) error {
+	for _, vm := range vms {
+		rg, err := azureResourceGroup(vm)
+		if err != nil {
+			return err
+		}
+
+		merged := make(map[string]*string, len(vm.Tags)+len(tags))
+		for k, v := range vm.Tags {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			if v == "" {
+				delete(merged, k)
+			} else {
+				merged[k] = to.Ptr(v)
+			}
+		}
+
+		poller, err := r.Client.BeginUpdate(azureCtx, rg, *vm.Name, armcompute.VirtualMachineUpdate{Tags: merged}, nil)
+		if err != nil {
+			return err
+		}
+
+		if _, err = poller.PollUntilDone(azureCtx, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getVMDetails returns the virtual machines tagged with the given name tag, along with their lock tags.
+func (r *AzureVMResource) getVMDetails(nameTag string) (azureVMDetails, error) {
+	details := azureVMDetails{Tags: make(map[string]string)}
+
+	vms, err := listAzureVMs(r.Client, defaultTagKey, nameTag)
+	if err != nil {
+		return details, err
+	}
+	details.VMs = vms
+
+	// The virtual machines of a resource are locked together, so the tags of any of them are the lock of the resource
+	for _, vm := range vms {
+		for _, k := range []string{lockedByTag, lockedUntilTag} {
+			if v, ok := vm.Tags[k]; ok && v != nil {
+				details.Tags[k] = *v
+			}
+		}
+
+		if len(details.Tags) == 2 {
+			break
+		}
+	}
+
+	return details, nil
+}
+
+// GetNewResources compares the local cluster resources with the ones returned from Azure
+// and gives back a list of resources that need to be created on the cluster.
+func (m *AzureVMMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags, err := getUniqueAzureVMTags(m.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// getUniqueAzureVMTags collects the resource names of all the virtual machines that are marked as managed by the operator.
+func getUniqueAzureVMTags(azureVMClient *armcompute.VirtualMachinesClient) (map[string]bool, error) {
+	tagMap := make(map[string]bool)
+
+	vms, err := listAzureVMs(azureVMClient, resourceMonitorTagKey, "true")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, vm := range vms {
+		if v, ok := vm.Tags[defaultTagKey]; ok && v != nil {
+			tagMap[*v] = true
+		}
+	}
+
+	return tagMap, nil
+}
+
+// listAzureVMs returns the virtual machines of the subscription that have the given tag set to the given value.
+// The Azure API can't filter machines by tags, so the filtering is done here.
+func listAzureVMs(azureVMClient *armcompute.VirtualMachinesClient, tagKey, tagValue string) ([]*armcompute.VirtualMachine, error) {
+	var vms []*armcompute.VirtualMachine
+	pager := azureVMClient.NewListAllPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(azureCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, vm := range page.Value {
+			if v, ok := vm.Tags[tagKey]; ok && v != nil && *v == tagValue {
+				vms = append(vms, vm)
+			}
+		}
+	}
+
+	return vms, nil
+}
+
+// azureResourceGroup extracts the resource group name from the ID of the virtual machine.
+func azureResourceGroup(vm *armcompute.VirtualMachine) (string, error) {
+	if vm.ID == nil {
+		return "", errors.New("virtual machine has no ID")
+	}
+
+	id, err := arm.ParseResourceID(*vm.ID)
+	if err != nil {
+		return "", err
+	}
+
+	return id.ResourceGroupName, nil
+}
@@ -0,0 +1,168 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5/fake"
+)
+
+// fakeAzureVMs keeps the virtual machines and their power state, and serves them through the SDK fake server.
+type fakeAzureVMs struct {
+	mu      sync.Mutex
+	vms     map[string]*armcompute.VirtualMachine
+	running map[string]bool
+}
+
+func (f *fakeAzureVMs) server() *fake.VirtualMachinesServer {
+	return &fake.VirtualMachinesServer{
+		NewListAllPager: func(options *armcompute.VirtualMachinesClientListAllOptions) (resp azfake.PagerResponder[armcompute.VirtualMachinesClientListAllResponse]) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			var page armcompute.VirtualMachinesClientListAllResponse
+			for _, vm := range f.vms {
+				page.Value = append(page.Value, vm)
+			}
+			resp.AddPage(http.StatusOK, page, nil)
+			return
+		},
+		InstanceView: func(ctx context.Context, rg, name string, options *armcompute.VirtualMachinesClientInstanceViewOptions) (resp azfake.Responder[armcompute.VirtualMachinesClientInstanceViewResponse], errResp azfake.ErrorResponder) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			state := "PowerState/deallocated"
+			if f.running[name] {
+				state = azurePowerStateRunning
+			}
+			view := armcompute.VirtualMachinesClientInstanceViewResponse{}
+			view.Statuses = []*armcompute.InstanceViewStatus{{Code: to.Ptr("ProvisioningState/succeeded")}, {Code: to.Ptr(state)}}
+			resp.SetResponse(http.StatusOK, view, nil)
+			return
+		},
+		BeginStart: func(ctx context.Context, rg, name string, options *armcompute.VirtualMachinesClientBeginStartOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientStartResponse], errResp azfake.ErrorResponder) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			f.running[name] = true
+			resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachinesClientStartResponse{}, nil)
+			return
+		},
+		BeginDeallocate: func(ctx context.Context, rg, name string, options *armcompute.VirtualMachinesClientBeginDeallocateOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientDeallocateResponse], errResp azfake.ErrorResponder) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			f.running[name] = false
+			resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachinesClientDeallocateResponse{}, nil)
+			return
+		},
+		BeginUpdate: func(ctx context.Context, rg, name string, parameters armcompute.VirtualMachineUpdate, options *armcompute.VirtualMachinesClientBeginUpdateOptions) (resp azfake.PollerResponder[armcompute.VirtualMachinesClientUpdateResponse], errResp azfake.ErrorResponder) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+
+			vm := f.vms[name]
+			vm.Tags = parameters.Tags
+			resp.SetTerminalResponse(http.StatusOK, armcompute.VirtualMachinesClientUpdateResponse{VirtualMachine: *vm}, nil)
+			return
+		},
+	}
+}
+
+func setupFakeAzureVMs(t *testing.T, vms ...*armcompute.VirtualMachine) (*fakeAzureVMs, *armcompute.VirtualMachinesClient) {
+	f := &fakeAzureVMs{vms: make(map[string]*armcompute.VirtualMachine), running: make(map[string]bool)}
+	for _, vm := range vms {
+		f.vms[*vm.Name] = vm
+	}
+
+	opts := &arm.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: fake.NewVirtualMachinesServerTransport(f.server())}}
+	client, err := armcompute.NewVirtualMachinesClient("subscription-id", &azfake.TokenCredential{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return f, client
+}
+
+func newAzureVM(name string, tags map[string]string) *armcompute.VirtualMachine {
+	vm := &armcompute.VirtualMachine{
+		ID:   to.Ptr(fmt.Sprintf("/subscriptions/subscription-id/resourceGroups/dev/providers/Microsoft.Compute/virtualMachines/%s", name)),
+		Name: to.Ptr(name),
+		Tags: make(map[string]*string),
+	}
+	for k, v := range tags {
+		vm.Tags[k] = to.Ptr(v)
+	}
+	return vm
+}
+
+func TestAzureVMResourceStartStop(t *testing.T) {
+	f, client := setupFakeAzureVMs(t,
+		newAzureVM("analytics-1", map[string]string{defaultTagKey: "analytics"}),
+		newAzureVM("analytics-2", map[string]string{defaultTagKey: "analytics"}),
+		newAzureVM("reporting-1", map[string]string{defaultTagKey: "reporting"}),
+	)
+	resource := &AzureVMResource{NameTag: "analytics", Client: client}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 2, Running: 2, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+	if f.running["reporting-1"] {
+		t.Error("virtual machine of another resource was started")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); err == nil {
+		t.Error("Stop() by another user should fail while the resource is locked")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 2}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+	if tags := f.vms["analytics-1"].Tags; len(tags) != 1 || *tags[defaultTagKey] != "analytics" {
+		t.Errorf("tags after Stop() = %v, want only the %s tag", tags, defaultTagKey)
+	}
+}
+
+func TestAzureVMMonitorGetNewResources(t *testing.T) {
+	_, client := setupFakeAzureVMs(t,
+		newAzureVM("analytics-1", map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"}),
+		newAzureVM("reporting-1", map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"}),
+		newAzureVM("unmanaged-1", map[string]string{defaultTagKey: "unmanaged"}),
+	)
+	monitor := &AzureVMMonitor{Type: TypeAzureVM, Client: client}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
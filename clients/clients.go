@@ -4,8 +4,11 @@ package clients
 import (
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -16,8 +19,15 @@ import (
 )
 
 const (
-	TypeEC2 string = "ec2"
-	TypeRDS string = "rds"
+	TypeEC2         string = "ec2"
+	TypeRDS         string = "rds"
+	TypeRDSCluster  string = "rds-cluster"
+	TypeECS         string = "ecs"
+	TypeASG         string = "asg"
+	TypeGCE         string = "gce"
+	TypeAzureVM     string = "azurevm"
+	TypeK8sWorkload string = "k8s-workload"
+	TypeFake        string = "fake"
 )
 
 const (
@@ -32,16 +42,64 @@ type ResourceStatusOutput struct {
 	LockedBy, LockedUntil string
 }
 
+// LockedError is returned when starting or stopping a resource that is locked by another user.
+type LockedError struct {
+	LockedBy, LockedUntil string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("Resource is locked by %s. The lock expires at %s.", e.LockedBy, e.LockedUntil)
+}
+
 // ResourceStartInput stores data that is used for book-keeping during the starting of the resource
 type ResourceStartInput struct {
 	UID, EndAt string
+	// Preempts is the user whose booking was preempted by the booking of UID. Their lock is taken over instead of
+	// keeping the resource from starting.
+	Preempts string
 }
 
-// ResourceStartInput stores data that is used for book-keeping during the stopping of the resource
+// ResourceStopInput stores data that is used for book-keeping during the stopping of the resource
 type ResourceStopInput struct {
 	UID string
 }
 
+// checkLock returns a LockedError when the lock tags of a resource lock it for another user than uid until a time that
+// didn't pass yet. A lock of the preempted user doesn't keep uid from taking the resource over.
+func checkLock(uid string, tags map[string]string, preempts string) error {
+	lockedBy, locked := tags[lockedByTag]
+	if !locked || tags[lockedUntilTag] == "" || lockedBy == uid || (preempts != "" && lockedBy == preempts) {
+		return nil
+	}
+
+	lockedUntil, err := time.Parse(time.RFC3339, tags[lockedUntilTag])
+	if err != nil {
+		return err
+	}
+	if time.Now().Before(lockedUntil) {
+		return &LockedError{LockedBy: lockedBy, LockedUntil: tags[lockedUntilTag]}
+	}
+
+	return nil
+}
+
+// Clients holds the cloud and cluster clients that the resources and monitors are created with.
+// They are built once on startup and passed through the reconcilers. A nil client means that the
+// integration is not configured, and the factories refuse to create resources of its types.
+type Clients struct {
+	EC2     EC2API
+	RDS     RDSAPI
+	ECS     ECSAPI
+	ASG     ASGAPI
+	GCE     *GCEClient
+	AzureVM *armcompute.VirtualMachinesClient
+	// Workload manages the in-cluster workloads of k8s-workload resources. They can live in any namespace,
+	// so unlike the manager client, it is neither cached nor limited to the namespace of the operator.
+	Workload client.Client
+	// Fake is the in-memory cloud of fake resources, for local development and tests.
+	Fake *FakeCloud
+}
+
 // ClientCache holds the client and cache objects.
 type ClientCache struct {
 	Client client.Client
@@ -56,6 +114,12 @@ type CloudResource interface {
 	Status() (ResourceStatusOutput, error)
 }
 
+// AutoStartedResource is implemented by the resources whose cloud provider starts stopped instances again on its own.
+// StopAutoStarted stops such instances, and returns their identifiers.
+type AutoStartedResource interface {
+	StopAutoStarted() ([]string, error)
+}
+
 type ResourceMonitor interface {
 	GetNewResources(clusterResources map[string]bool) ([]string, error)
 }
@@ -82,14 +146,32 @@ func init() {
 
 // ResourceFactory generates structs that abide by the CloudResource interface.
 // The returned struct can start, stop, and list instances. Each new integration needso to be added to this factory function.
-func ResourceFactory(resType, tag string) (CloudResource, error) {
+func ResourceFactory(resType, tag string, c Clients) (CloudResource, error) {
 	var resource CloudResource
 
+	if !c.configured(resType) {
+		return nil, fmt.Errorf("Client for resource type %s is not configured", resType)
+	}
+
 	switch resType {
 	case TypeEC2:
-		resource = &EC2Resource{NameTag: tag}
+		resource = &EC2Resource{NameTag: tag, Client: c.EC2}
 	case TypeRDS:
-		resource = &RDSResource{NameTag: tag}
+		resource = &RDSResource{NameTag: tag, Client: c.RDS}
+	case TypeRDSCluster:
+		resource = &RDSClusterResource{NameTag: tag, Client: c.RDS}
+	case TypeECS:
+		resource = &ECSResource{NameTag: tag, Client: c.ECS}
+	case TypeASG:
+		resource = &ASGResource{NameTag: tag, Client: c.ASG}
+	case TypeGCE:
+		resource = &GCEResource{NameTag: tag, Client: c.GCE}
+	case TypeAzureVM:
+		resource = &AzureVMResource{NameTag: tag, Client: c.AzureVM}
+	case TypeK8sWorkload:
+		resource = &K8sWorkloadResource{NameTag: tag, Client: c.Workload}
+	case TypeFake:
+		resource = &FakeResource{NameTag: tag, Cloud: c.Fake}
 	default:
 		return nil, errors.New("Resource type not found")
 	}
@@ -99,14 +181,32 @@ func ResourceFactory(resType, tag string) (CloudResource, error) {
 
 // MonitorFactory generates structs that abide by the ResourceMonitor interface.
 // The returned struct can get new resources of the specified type. Each new integration needso to be added to this factory function.
-func MonitorFactory(monitorType string) (ResourceMonitor, error) {
+func MonitorFactory(monitorType string, c Clients) (ResourceMonitor, error) {
 	var resourceMonitor ResourceMonitor
 
+	if !c.configured(monitorType) {
+		return nil, fmt.Errorf("Client for monitor type %s is not configured", monitorType)
+	}
+
 	switch monitorType {
 	case TypeEC2:
-		resourceMonitor = &EC2Monitor{Type: monitorType}
+		resourceMonitor = &EC2Monitor{Type: monitorType, Client: c.EC2}
 	case TypeRDS:
-		resourceMonitor = &RDSMonitor{Type: monitorType}
+		resourceMonitor = &RDSMonitor{Type: monitorType, Client: c.RDS}
+	case TypeRDSCluster:
+		resourceMonitor = &RDSClusterMonitor{Type: monitorType, Client: c.RDS}
+	case TypeECS:
+		resourceMonitor = &ECSMonitor{Type: monitorType, Client: c.ECS}
+	case TypeASG:
+		resourceMonitor = &ASGMonitor{Type: monitorType, Client: c.ASG}
+	case TypeGCE:
+		resourceMonitor = &GCEMonitor{Type: monitorType, Client: c.GCE}
+	case TypeAzureVM:
+		resourceMonitor = &AzureVMMonitor{Type: monitorType, Client: c.AzureVM}
+	case TypeK8sWorkload:
+		resourceMonitor = &K8sWorkloadMonitor{Type: monitorType, Client: c.Workload}
+	case TypeFake:
+		resourceMonitor = &FakeMonitor{Type: monitorType, Cloud: c.Fake}
 	default:
 		return nil, errors.New("Monitor type not found")
 	}
@@ -114,6 +214,30 @@ func MonitorFactory(monitorType string) (ResourceMonitor, error) {
 	return resourceMonitor, nil
 }
 
+// configured reports whether the client that the given type needs is set. Unknown types are left to the factories.
+func (c Clients) configured(resType string) bool {
+	switch resType {
+	case TypeEC2:
+		return c.EC2 != nil
+	case TypeRDS, TypeRDSCluster:
+		return c.RDS != nil
+	case TypeECS:
+		return c.ECS != nil
+	case TypeASG:
+		return c.ASG != nil
+	case TypeGCE:
+		return c.GCE != nil
+	case TypeAzureVM:
+		return c.AzureVM != nil
+	case TypeK8sWorkload:
+		return c.Workload != nil
+	case TypeFake:
+		return c.Fake != nil
+	}
+
+	return true
+}
+
 // GetClient returns a ready to use kubernetes client and cache.
 func GetClient() (ClientCache, error) {
 	var clientCache ClientCache
@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckLock(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	lockedBy := func(user, until string) map[string]string {
+		return map[string]string{lockedByTag: user, lockedUntilTag: until}
+	}
+
+	tests := []struct {
+		name     string
+		tags     map[string]string
+		preempts string
+		locked   bool
+	}{
+		{name: "unlocked", tags: map[string]string{}},
+		{name: "locked by the user", tags: lockedBy("alice", future)},
+		{name: "locked by another user", tags: lockedBy("bob", future), locked: true},
+		{name: "expired lock of another user", tags: lockedBy("bob", past)},
+		{name: "lock of the preempted user", tags: lockedBy("bob", future), preempts: "bob"},
+		{name: "lock of another user than the preempted one", tags: lockedBy("bob", future), preempts: "carol", locked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLock("alice", tt.tags, tt.preempts)
+
+			var locked *LockedError
+			if got := errors.As(err, &locked); got != tt.locked || (!tt.locked && err != nil) {
+				t.Errorf("checkLock() = %v, want locked %v", err, tt.locked)
+			}
+		})
+	}
+
+	if err := checkLock("alice", lockedBy("bob", "tomorrow"), ""); err == nil {
+		t.Error("checkLock() with an unparsable lock expiry should fail")
+	}
+}
@@ -5,8 +5,6 @@ package clients
 import (
 	"context"
 	"fmt"
-	"os"
-	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -33,13 +31,25 @@ var (
 	lockedUntilTag string = "resource-booking-locked-until"
 )
 
+// EC2API is the part of the EC2 client that the EC2 resources and monitors use.
+type EC2API interface {
+	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
+	DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error)
+	StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
+	StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
+	DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error)
+}
+
 type EC2Monitor struct {
-	Type string
+	Type   string
+	Client EC2API
 }
 
 // Resource represents a collection of EC2 instances grouped by a common "resource-booking-application" tag.
 type EC2Resource struct {
 	NameTag string
+	Client  EC2API
 }
 
 type instanceDetails struct {
@@ -47,25 +57,21 @@ type instanceDetails struct {
 	Tags map[string]string
 }
 
-var ec2Client *ec2.Client
 var ctx = context.Background()
 
-func init() {
+// LoadAWSConfig loads the default AWS config that the AWS clients are built with.
+// When a role ARN is given, the config holds the credentials of the assumed role instead.
+func LoadAWSConfig(roleArn string) (aws.Config, error) {
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
-		fmt.Printf("unable to load SDK config: %v\n", err)
-		os.Exit(1)
+		return cfg, fmt.Errorf("unable to load SDK config: %w", err)
 	}
 
-	if roleArn := os.Getenv("AWS_ASSUME_ROLE_ARN"); roleArn != "" {
-		cfg, err = assumeRole(cfg, roleArn)
-		if err != nil {
-			fmt.Println(err.Error())
-			os.Exit(1)
-		}
+	if roleArn != "" {
+		return assumeRole(cfg, roleArn)
 	}
 
-	ec2Client = ec2.NewFromConfig(cfg)
+	return cfg, nil
 }
 
 // Start makes a call through the EC2 client to start resource instances by their IDs.
@@ -76,11 +82,11 @@ func (r *EC2Resource) Start(startInput ResourceStartInput) error {
 		return err
 	}
 
-	if _, err = r.canManage(startInput.UID, instances.Tags); err != nil {
+	if err = checkLock(startInput.UID, instances.Tags, startInput.Preempts); err != nil {
 		return err
 	}
 
-	_, err = ec2Client.StartInstances(ctx, &ec2.StartInstancesInput{
+	_, err = r.Client.StartInstances(ctx, &ec2.StartInstancesInput{
 		InstanceIds: instances.IDs,
 	})
 	if err != nil {
@@ -102,11 +108,11 @@ func (r *EC2Resource) Stop(stopInput ResourceStopInput) error {
 		return err
 	}
 
-	if _, err = r.canManage(stopInput.UID, instances.Tags); err != nil {
+	if err = checkLock(stopInput.UID, instances.Tags, ""); err != nil {
 		return err
 	}
 
-	_, err = ec2Client.StopInstances(ctx, &ec2.StopInstancesInput{
+	_, err = r.Client.StopInstances(ctx, &ec2.StopInstancesInput{
 		InstanceIds: instances.IDs,
 	})
 	if err != nil {
@@ -132,7 +138,7 @@ func (r *EC2Resource) Status() (ResourceStatusOutput, error) {
 		return rst, err
 	}
 
-	resp, err := ec2Client.DescribeInstanceStatus(ctx, &ec2.DescribeInstanceStatusInput{
+	resp, err := r.Client.DescribeInstanceStatus(ctx, &ec2.DescribeInstanceStatusInput{
 		IncludeAllInstances: &includeAll,
 		InstanceIds:         instances.IDs,
 	})
@@ -155,28 +161,11 @@ func (r *EC2Resource) Status() (ResourceStatusOutput, error) {
 	return rst, nil
 }
 
-func (r *EC2Resource) canManage(uid string, instanceTags map[string]string) (bool, error) {
-	if _, ok := instanceTags[lockedByTag]; ok && instanceTags[lockedUntilTag] != "" {
-		d, err := time.Parse(time.RFC3339, instanceTags[lockedUntilTag])
-		if err != nil {
-			return false, err
-		}
-
-		if instanceTags[lockedByTag] != uid && time.Now().Before(d) {
-			m := "Resource is locked by %s. The lock expires at %s."
-			err = fmt.Errorf(m, instanceTags[lockedByTag], instanceTags[lockedUntilTag])
-			return false, err
-		}
-	}
-
-	return true, nil
-}
-
 // lock sets locking tags to the resource instances. Tags are:
 // resource-booking-locked-by    - The identifier of the booking that owns the instance at this moment
 // resource-booking-locked-until - Date time until the instance is available again. The endAt of the booking.
 func (r *EC2Resource) lock(uid string, endAt string, instanceIDs []string) error {
-	_, err := ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{
+	_, err := r.Client.CreateTags(ctx, &ec2.CreateTagsInput{
 		Resources: instanceIDs,
 		Tags: []types.Tag{
 			{Key: &lockedByTag, Value: &uid},
@@ -193,7 +182,7 @@ func (r *EC2Resource) lock(uid string, endAt string, instanceIDs []string) error
 
 // unlock removes the locking tags, freeing the resource to other users.
 func (r *EC2Resource) unlock(instanceIDs []string) error {
-	_, err := ec2Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
+	_, err := r.Client.DeleteTags(ctx, &ec2.DeleteTagsInput{
 		Resources: instanceIDs,
 		Tags: []types.Tag{
 			{Key: &lockedByTag},
@@ -221,7 +210,7 @@ func (r *EC2Resource) getInstanceDetails(nameTag string) (instanceDetails, error
 		Values: []string{nameTag},
 	}
 
-	resp, err := ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+	resp, err := r.Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		Filters: []types.Filter{nameFilter},
 	})
 	if err != nil {
@@ -235,7 +224,7 @@ func (r *EC2Resource) getInstanceDetails(nameTag string) (instanceDetails, error
 		}
 	}
 
-	// For now Don't care about the edge case where two instances might theoretically have different lock tags
+	// The instances of a resource are locked together, so the tags of any of them are the lock of the resource
 	for _, v := range instanceTagList {
 		if *v.Key == lockedByTag || *v.Key == lockedUntilTag {
 			details.Tags[*v.Key] = *v.Value
@@ -252,7 +241,7 @@ func (r *EC2Resource) getInstanceDetails(nameTag string) (instanceDetails, error
 // GetNewResources compares the local cluster resources with the ones returned from EC2
 // and gives back a list of resources that need to be created on the cluster.
 func (m *EC2Monitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
-	uniqueTags, err := GetUniqueTags()
+	uniqueTags, err := GetUniqueTags(m.Client)
 	if err != nil {
 		return nil, err
 	}
@@ -264,7 +253,7 @@ func (m *EC2Monitor) GetNewResources(clusterResources map[string]bool) ([]string
 }
 
 // GetUniqueTags makes a call through the EC2 client to collect all instance tags and returns a set of them
-func GetUniqueTags() (map[string]bool, error) {
+func GetUniqueTags(ec2Client EC2API) (map[string]bool, error) {
 	// Prepare filters
 	tagKey := "tag:" + resourceMonitorTagKey
 	tagValue := "true"
@@ -0,0 +1,210 @@
+package clients
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// fakeEC2Stopped is the state code of a stopped instance.
+const fakeEC2Stopped int32 = 80
+
+// fakeEC2 keeps the instances in memory and implements the EC2 calls the EC2 backend makes.
+// Filters are limited to the single tag filters used by the backend.
+type fakeEC2 struct {
+	mu        sync.Mutex
+	instances map[string]*types.Instance
+}
+
+func newFakeEC2(instances ...types.Instance) *fakeEC2 {
+	f := &fakeEC2{instances: make(map[string]*types.Instance)}
+	for i := range instances {
+		f.instances[*instances[i].InstanceId] = &instances[i]
+	}
+	return f
+}
+
+func (f *fakeEC2) DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var reservation types.Reservation
+	for _, inst := range f.instances {
+		if fakeEC2Matches(inst, params.Filters) {
+			reservation.Instances = append(reservation.Instances, *inst)
+		}
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{reservation}}, nil
+}
+
+func (f *fakeEC2) DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out ec2.DescribeInstanceStatusOutput
+	for _, id := range params.InstanceIds {
+		out.InstanceStatuses = append(out.InstanceStatuses, types.InstanceStatus{InstanceId: aws.String(id), InstanceState: f.instances[id].State})
+	}
+	return &out, nil
+}
+
+func (f *fakeEC2) StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+	f.setState(params.InstanceIds, statusRunning)
+	return &ec2.StartInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	f.setState(params.InstanceIds, fakeEC2Stopped)
+	return &ec2.StopInstancesOutput{}, nil
+}
+
+func (f *fakeEC2) CreateTags(ctx context.Context, params *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range params.Resources {
+		inst := f.instances[id]
+		inst.Tags = fakeEC2DeleteTags(inst.Tags, params.Tags)
+		inst.Tags = append(inst.Tags, params.Tags...)
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (f *fakeEC2) DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range params.Resources {
+		f.instances[id].Tags = fakeEC2DeleteTags(f.instances[id].Tags, params.Tags)
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (f *fakeEC2) setState(ids []string, code int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, id := range ids {
+		f.instances[id].State = &types.InstanceState{Code: aws.Int32(code)}
+	}
+}
+
+func fakeEC2Matches(inst *types.Instance, filters []types.Filter) bool {
+	for _, filter := range filters {
+		v, ok := fakeEC2Tag(inst.Tags, (*filter.Name)[len("tag:"):])
+		if !ok || len(filter.Values) == 0 || v != filter.Values[0] {
+			return false
+		}
+	}
+	return true
+}
+
+func fakeEC2DeleteTags(tags, remove []types.Tag) []types.Tag {
+	var kept []types.Tag
+	for _, t := range tags {
+		if _, ok := fakeEC2Tag(remove, *t.Key); !ok {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// fakeEC2Tag looks up a tag by key, ignoring whether the tag has a value.
+func fakeEC2Tag(tags []types.Tag, key string) (string, bool) {
+	for _, t := range tags {
+		if *t.Key == key {
+			return aws.ToString(t.Value), true
+		}
+	}
+	return "", false
+}
+
+func newEC2Instance(id string, tags map[string]string) types.Instance {
+	inst := types.Instance{InstanceId: aws.String(id), State: &types.InstanceState{Code: aws.Int32(fakeEC2Stopped)}}
+	for k, v := range tags {
+		inst.Tags = append(inst.Tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return inst
+}
+
+func TestEC2ResourceStartStop(t *testing.T) {
+	fake := newFakeEC2(
+		newEC2Instance("i-analytics-1", map[string]string{defaultTagKey: "analytics"}),
+		newEC2Instance("i-analytics-2", map[string]string{defaultTagKey: "analytics"}),
+		newEC2Instance("i-reporting-1", map[string]string{defaultTagKey: "reporting"}),
+	)
+	resource := &EC2Resource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 2, Running: 2, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+	if code := *fake.instances["i-reporting-1"].State.Code; code == statusRunning {
+		t.Error("instance of another resource was started")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); err == nil {
+		t.Error("Stop() by another user should fail while the resource is locked")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 2}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+}
+
+func TestEC2MonitorGetNewResources(t *testing.T) {
+	fake := newFakeEC2(
+		newEC2Instance("i-analytics-1", map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"}),
+		newEC2Instance("i-reporting-1", map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"}),
+		newEC2Instance("i-unmanaged-1", map[string]string{defaultTagKey: "unmanaged"}),
+	)
+	monitor := &EC2Monitor{Type: TypeEC2, Client: fake}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
+
+func TestFactoriesRequireConfiguredClients(t *testing.T) {
+	if _, err := ResourceFactory(TypeEC2, "analytics", Clients{}); err == nil {
+		t.Error("ResourceFactory() without an EC2 client should fail")
+	}
+	if _, err := MonitorFactory(TypeRDS, Clients{}); err == nil {
+		t.Error("MonitorFactory() without an RDS client should fail")
+	}
+
+	resource, err := ResourceFactory(TypeEC2, "analytics", Clients{EC2: newFakeEC2()})
+	if err != nil {
+		t.Fatalf("ResourceFactory() error = %v", err)
+	}
+	if _, ok := resource.(*EC2Resource); !ok {
+		t.Errorf("ResourceFactory() = %T, want *EC2Resource", resource)
+	}
+}
@@ -0,0 +1,283 @@
+package clients
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// desiredCountTag stores the desired count a service had before it was scaled to zero.
+const desiredCountTag string = "resource-booking-desired-count"
+
+// ecsDescribeLimit is the maximum number of services DescribeServices accepts in a single call.
+const ecsDescribeLimit = 10
+
+// ECSAPI is the part of the ECS client that the ECS resources and monitors use.
+type ECSAPI interface {
+	ListClusters(ctx context.Context, params *ecs.ListClustersInput, optFns ...func(*ecs.Options)) (*ecs.ListClustersOutput, error)
+	ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error)
+	DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error)
+	UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error)
+	TagResource(ctx context.Context, params *ecs.TagResourceInput, optFns ...func(*ecs.Options)) (*ecs.TagResourceOutput, error)
+	UntagResource(ctx context.Context, params *ecs.UntagResourceInput, optFns ...func(*ecs.Options)) (*ecs.UntagResourceOutput, error)
+}
+
+// ECSResource represents a collection of ECS services grouped by a common "resource-booking-application" tag.
+type ECSResource struct {
+	NameTag string
+	Client  ECSAPI
+}
+
+type ECSMonitor struct {
+	Type   string
+	Client ECSAPI
+}
+
+type ecsServiceDetails struct {
+	Services []types.Service
+	Tags     map[string]string
+}
+
+var ecsCtx = context.Background()
+
+// Start sets the desired count of the resource services back to the one they had before they were stopped, and locks them with tags.
+func (r *ECSResource) Start(startInput ResourceStartInput) error {
+	services, err := r.getServiceDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(startInput.UID, services.Tags, startInput.Preempts); err != nil {
+		return err
+	}
+
+	for _, svc := range services.Services {
+		if count, ok := ecsTag(svc.Tags, desiredCountTag); ok {
+			desired, err := strconv.ParseInt(count, 10, 32)
+			if err != nil {
+				return err
+			}
+
+			_, err = r.Client.UpdateService(ecsCtx, &ecs.UpdateServiceInput{
+				Cluster:      svc.ClusterArn,
+				Service:      svc.ServiceArn,
+				DesiredCount: aws.Int32(int32(desired)),
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = r.Client.UntagResource(ecsCtx, &ecs.UntagResourceInput{
+				ResourceArn: svc.ServiceArn,
+				TagKeys:     []string{desiredCountTag},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = r.Client.TagResource(ecsCtx, &ecs.TagResourceInput{
+			ResourceArn: svc.ServiceArn,
+			Tags: []types.Tag{
+				{Key: &lockedByTag, Value: &startInput.UID},
+				{Key: &lockedUntilTag, Value: &startInput.EndAt},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop sets the desired count of the resource services to zero, remembering the previous one in a tag, and removes their lock tags.
+func (r *ECSResource) Stop(stopInput ResourceStopInput) error {
+	services, err := r.getServiceDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(stopInput.UID, services.Tags, ""); err != nil {
+		return err
+	}
+
+	for _, svc := range services.Services {
+		if svc.DesiredCount > 0 {
+			count := strconv.Itoa(int(svc.DesiredCount))
+			_, err = r.Client.TagResource(ecsCtx, &ecs.TagResourceInput{
+				ResourceArn: svc.ServiceArn,
+				Tags:        []types.Tag{{Key: aws.String(desiredCountTag), Value: &count}},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = r.Client.UpdateService(ecsCtx, &ecs.UpdateServiceInput{
+			Cluster:      svc.ClusterArn,
+			Service:      svc.ServiceArn,
+			DesiredCount: aws.Int32(0),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = r.Client.UntagResource(ecsCtx, &ecs.UntagResourceInput{
+			ResourceArn: svc.ServiceArn,
+			TagKeys:     []string{lockedByTag, lockedUntilTag},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status returns the running tasks of the resource services as running, and their desired tasks as available.
+// The desired tasks of a stopped service are the ones it will be set back to on start.
+func (r *ECSResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	services, err := r.getServiceDetails(r.NameTag)
+	if err != nil {
+		return rst, err
+	}
+
+	for _, svc := range services.Services {
+		desired := svc.DesiredCount
+		if count, ok := ecsTag(svc.Tags, desiredCountTag); ok && desired == 0 {
+			remembered, err := strconv.ParseInt(count, 10, 32)
+			if err != nil {
+				return rst, err
+			}
+			desired = int32(remembered)
+		}
+
+		rst.Available += int(desired)
+		rst.Running += int(svc.RunningCount)
+	}
+
+	rst.LockedBy, rst.LockedUntil = services.Tags[lockedByTag], services.Tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// getServiceDetails returns the services tagged with the given name tag, along with their lock tags.
+func (r *ECSResource) getServiceDetails(nameTag string) (ecsServiceDetails, error) {
+	details := ecsServiceDetails{Tags: make(map[string]string)}
+
+	services, err := listECSServices(r.Client, defaultTagKey, nameTag)
+	if err != nil {
+		return details, err
+	}
+	details.Services = services
+
+	// The services of a resource are locked together, so the tags of any of them are the lock of the resource
+	for _, svc := range services {
+		for _, k := range []string{lockedByTag, lockedUntilTag} {
+			if v, ok := ecsTag(svc.Tags, k); ok {
+				details.Tags[k] = v
+			}
+		}
+
+		if len(details.Tags) == 2 {
+			break
+		}
+	}
+
+	return details, nil
+}
+
+// GetNewResources compares the local cluster resources with the ones returned from ECS
+// and gives back a list of resources that need to be created on the cluster.
+func (m *ECSMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags := make(map[string]bool)
+
+	services, err := listECSServices(m.Client, resourceMonitorTagKey, "true")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, svc := range services {
+		if v, ok := ecsTag(svc.Tags, defaultTagKey); ok {
+			uniqueTags[v] = true
+		}
+	}
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// listECSServices returns the services of all clusters that have the given tag set to the given value.
+// ECS can't filter services by tags, so they are all described and filtered here.
+func listECSServices(ecsClient ECSAPI, tagKey, tagValue string) ([]types.Service, error) {
+	var services []types.Service
+
+	clusters := ecs.NewListClustersPaginator(ecsClient, &ecs.ListClustersInput{})
+	for clusters.HasMorePages() {
+		clusterPage, err := clusters.NextPage(ecsCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range clusterPage.ClusterArns {
+			serviceArns, err := listECSServiceArns(ecsClient, cluster)
+			if err != nil {
+				return nil, err
+			}
+
+			for start := 0; start < len(serviceArns); start += ecsDescribeLimit {
+				end := min(start+ecsDescribeLimit, len(serviceArns))
+				resp, err := ecsClient.DescribeServices(ecsCtx, &ecs.DescribeServicesInput{
+					Cluster:  aws.String(cluster),
+					Services: serviceArns[start:end],
+					Include:  []types.ServiceField{types.ServiceFieldTags},
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				for _, svc := range resp.Services {
+					if v, ok := ecsTag(svc.Tags, tagKey); ok && v == tagValue {
+						services = append(services, svc)
+					}
+				}
+			}
+		}
+	}
+
+	return services, nil
+}
+
+// listECSServiceArns returns the ARNs of all the services in the given cluster.
+func listECSServiceArns(ecsClient ECSAPI, cluster string) ([]string, error) {
+	var arns []string
+
+	pages := ecs.NewListServicesPaginator(ecsClient, &ecs.ListServicesInput{Cluster: aws.String(cluster)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ecsCtx)
+		if err != nil {
+			return nil, err
+		}
+		arns = append(arns, page.ServiceArns...)
+	}
+
+	return arns, nil
+}
+
+// ecsTag returns the value of the tag with the given key.
+func ecsTag(tags []types.Tag, key string) (string, bool) {
+	for _, t := range tags {
+		if t.Key != nil && *t.Key == key && t.Value != nil {
+			return *t.Value, true
+		}
+	}
+	return "", false
+}
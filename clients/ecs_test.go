@@ -0,0 +1,226 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// fakeECSCluster is the only cluster of the fake ECS API.
+const fakeECSCluster = "arn:aws:ecs:eu-west-1:123456789012:cluster/default"
+
+// fakeECS keeps the services of a single cluster in memory and implements the ECS calls the ECS backend makes.
+// Services reach their desired count as soon as it is updated.
+type fakeECS struct {
+	mu       sync.Mutex
+	services map[string]*types.Service
+}
+
+func newFakeECS(services ...types.Service) *fakeECS {
+	f := &fakeECS{services: make(map[string]*types.Service)}
+	for i := range services {
+		f.services[*services[i].ServiceArn] = &services[i]
+	}
+	return f
+}
+
+func (f *fakeECS) ListClusters(ctx context.Context, params *ecs.ListClustersInput, optFns ...func(*ecs.Options)) (*ecs.ListClustersOutput, error) {
+	return &ecs.ListClustersOutput{ClusterArns: []string{fakeECSCluster}}, nil
+}
+
+func (f *fakeECS) ListServices(ctx context.Context, params *ecs.ListServicesInput, optFns ...func(*ecs.Options)) (*ecs.ListServicesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out ecs.ListServicesOutput
+	for arn := range f.services {
+		out.ServiceArns = append(out.ServiceArns, arn)
+	}
+	return &out, nil
+}
+
+func (f *fakeECS) DescribeServices(ctx context.Context, params *ecs.DescribeServicesInput, optFns ...func(*ecs.Options)) (*ecs.DescribeServicesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(params.Services) > ecsDescribeLimit {
+		return nil, errors.New("too many services")
+	}
+
+	var out ecs.DescribeServicesOutput
+	for _, arn := range params.Services {
+		svc := *f.services[arn]
+		svc.Tags = append([]types.Tag(nil), svc.Tags...)
+		out.Services = append(out.Services, svc)
+	}
+	return &out, nil
+}
+
+func (f *fakeECS) UpdateService(ctx context.Context, params *ecs.UpdateServiceInput, optFns ...func(*ecs.Options)) (*ecs.UpdateServiceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	svc := f.services[*params.Service]
+	svc.DesiredCount = *params.DesiredCount
+	svc.RunningCount = *params.DesiredCount
+	return &ecs.UpdateServiceOutput{}, nil
+}
+
+func (f *fakeECS) TagResource(ctx context.Context, params *ecs.TagResourceInput, optFns ...func(*ecs.Options)) (*ecs.TagResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	svc := f.services[*params.ResourceArn]
+	for _, tag := range params.Tags {
+		svc.Tags = fakeECSDeleteTags(svc.Tags, *tag.Key)
+	}
+	svc.Tags = append(svc.Tags, params.Tags...)
+	return &ecs.TagResourceOutput{}, nil
+}
+
+func (f *fakeECS) UntagResource(ctx context.Context, params *ecs.UntagResourceInput, optFns ...func(*ecs.Options)) (*ecs.UntagResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	svc := f.services[*params.ResourceArn]
+	svc.Tags = fakeECSDeleteTags(svc.Tags, params.TagKeys...)
+	return &ecs.UntagResourceOutput{}, nil
+}
+
+func fakeECSDeleteTags(tags []types.Tag, keys ...string) []types.Tag {
+	var kept []types.Tag
+	for _, t := range tags {
+		if !slices.Contains(keys, *t.Key) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func newECSService(name string, desired int32, tags map[string]string) types.Service {
+	svc := types.Service{
+		ServiceArn:   aws.String("arn:aws:ecs:eu-west-1:123456789012:service/default/" + name),
+		ClusterArn:   aws.String(fakeECSCluster),
+		DesiredCount: desired,
+		RunningCount: desired,
+	}
+	for k, v := range tags {
+		svc.Tags = append(svc.Tags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return svc
+}
+
+func (f *fakeECS) service(name string) *types.Service {
+	return f.services["arn:aws:ecs:eu-west-1:123456789012:service/default/"+name]
+}
+
+func TestECSResourceStartStop(t *testing.T) {
+	fake := newFakeECS(
+		newECSService("analytics-api", 2, map[string]string{defaultTagKey: "analytics"}),
+		newECSService("analytics-worker", 1, map[string]string{defaultTagKey: "analytics"}),
+		newECSService("reporting-api", 3, map[string]string{defaultTagKey: "reporting"}),
+	)
+	resource := &ECSResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 3}
+	if rst != want {
+		t.Errorf("Status() of the stopped resource = %+v, want %+v", rst, want)
+	}
+	if count, _ := ecsTag(fake.service("analytics-api").Tags, desiredCountTag); count != "2" {
+		t.Errorf("%s tag after Stop() = %q, want 2", desiredCountTag, count)
+	}
+	if got := fake.service("reporting-api").DesiredCount; got != 3 {
+		t.Errorf("service of another resource has desired count %d, want 3", got)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 3, Running: 3, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() of the started resource = %+v, want %+v", rst, want)
+	}
+	for name, desired := range map[string]int32{"analytics-api": 2, "analytics-worker": 1} {
+		svc := fake.service(name)
+		if svc.DesiredCount != desired {
+			t.Errorf("desired count of %s after Start() = %d, want %d", name, svc.DesiredCount, desired)
+		}
+		if _, ok := ecsTag(svc.Tags, desiredCountTag); ok {
+			t.Errorf("%s still has the %s tag after Start()", name, desiredCountTag)
+		}
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	for _, key := range []string{lockedByTag, lockedUntilTag} {
+		if _, ok := ecsTag(fake.service("analytics-api").Tags, key); ok {
+			t.Errorf("%s tag is left after Stop()", key)
+		}
+	}
+}
+
+func TestECSResourceLock(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	fake := newFakeECS(
+		newECSService("analytics-api", 2, map[string]string{defaultTagKey: "analytics", lockedByTag: "alice", lockedUntilTag: lockedUntil}),
+	)
+	resource := &ECSResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	var lockedErr *LockedError
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt}); !errors.As(err, &lockedErr) || lockedErr.LockedBy != "alice" {
+		t.Errorf("Start() by another user error = %v, want a lock of alice", err)
+	}
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); !errors.As(err, &lockedErr) {
+		t.Errorf("Stop() by another user error = %v, want a LockedError", err)
+	}
+	if got := fake.service("analytics-api").DesiredCount; got != 2 {
+		t.Errorf("desired count of the locked service = %d, want 2", got)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt, Preempts: "alice"}); err != nil {
+		t.Fatalf("Start() preempting the lock holder error = %v", err)
+	}
+	if lockedBy, _ := ecsTag(fake.service("analytics-api").Tags, lockedByTag); lockedBy != "bob" {
+		t.Errorf("%s after the preemption = %q, want bob", lockedByTag, lockedBy)
+	}
+}
+
+func TestECSMonitorGetNewResources(t *testing.T) {
+	fake := newFakeECS(
+		newECSService("analytics-api", 1, map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"}),
+		newECSService("reporting-api", 1, map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"}),
+		newECSService("unmanaged-api", 1, map[string]string{defaultTagKey: "unmanaged"}),
+	)
+	monitor := &ECSMonitor{Type: TypeECS, Client: fake}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
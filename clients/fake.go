@@ -0,0 +1,324 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeOperation names the fake resource calls that failures can be injected into.
+type FakeOperation string
+
+const (
+	FakeStart  FakeOperation = "start"
+	FakeStop   FakeOperation = "stop"
+	FakeStatus FakeOperation = "status"
+)
+
+const (
+	fakeStateStopped  string = "stopped"
+	fakeStatePending  string = "pending"
+	fakeStateRunning  string = "running"
+	fakeStateStopping string = "stopping"
+)
+
+// ErrFakeFailure is returned by the fake resources when a call fails at random.
+var ErrFakeFailure = errors.New("fake cloud failure")
+
+// FakeCloudOptions configures how the fake cloud behaves.
+type FakeCloudOptions struct {
+	// StartLatency and StopLatency are how long instances take to reach the running and stopped states.
+	StartLatency, StopLatency time.Duration
+	// FailureRate is the probability, from 0 to 1, that a start or stop call fails with ErrFakeFailure.
+	FailureRate float64
+}
+
+// FakeCloud is an in-memory cloud provider that backs the fake resource type. It simulates instances
+// with start and stop latency, lock tags and injected failures, so the operator can be run and tested without a real cloud.
+type FakeCloud struct {
+	opts FakeCloudOptions
+
+	mu        sync.Mutex
+	instances map[string]*fakeInstance
+	failures  map[string]error
+	nextID    int
+}
+
+type fakeInstance struct {
+	ID, NameTag string
+	Managed     bool
+	Tags        map[string]string
+	State       string
+	// Until is when a pending or stopping instance reaches its next state.
+	Until time.Time
+}
+
+// FakeResource represents a collection of fake cloud instances grouped by a common resource name.
+type FakeResource struct {
+	NameTag string
+	Cloud   *FakeCloud
+}
+
+type FakeMonitor struct {
+	Type  string
+	Cloud *FakeCloud
+}
+
+// NewFakeCloud creates an empty fake cloud.
+func NewFakeCloud(opts FakeCloudOptions) *FakeCloud {
+	return &FakeCloud{
+		opts:      opts,
+		instances: make(map[string]*fakeInstance),
+		failures:  make(map[string]error),
+	}
+}
+
+// AddInstances adds stopped instances to the resource with the given name, and returns their IDs.
+// Managed instances are picked up by the fake resource monitors.
+func (f *FakeCloud) AddInstances(nameTag string, count int, managed bool) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ids []string
+	for i := 0; i < count; i++ {
+		f.nextID++
+		id := fmt.Sprintf("fake-%d", f.nextID)
+		f.instances[id] = &fakeInstance{ID: id, NameTag: nameTag, Managed: managed, Tags: make(map[string]string), State: fakeStateStopped}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// Reset removes all instances and injected failures, leaving an empty fake cloud.
+func (f *FakeCloud) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.instances = make(map[string]*fakeInstance)
+	f.failures = make(map[string]error)
+}
+
+// InjectFailure makes every call of the given operation on the named resource fail with err, until it is called again with a nil error.
+func (f *FakeCloud) InjectFailure(op FakeOperation, nameTag string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := string(op) + "/" + nameTag
+	if err == nil {
+		delete(f.failures, key)
+	} else {
+		f.failures[key] = err
+	}
+}
+
+// SetState forces the state of an instance, like a cloud provider that starts or stops it on its own would.
+func (f *FakeCloud) SetState(id string, running bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	inst, ok := f.instances[id]
+	if !ok {
+		return fmt.Errorf("fake instance %s not found", id)
+	}
+
+	inst.State = fakeStateStopped
+	if running {
+		inst.State = fakeStateRunning
+	}
+
+	return nil
+}
+
+// failure returns the error the operation should fail with, if any. Only starts and stops fail at random.
+func (f *FakeCloud) failure(op FakeOperation, nameTag string) error {
+	if err, ok := f.failures[string(op)+"/"+nameTag]; ok {
+		return err
+	}
+
+	if op != FakeStatus && f.opts.FailureRate > 0 && rand.Float64() < f.opts.FailureRate {
+		return fmt.Errorf("%s of %s: %w", op, nameTag, ErrFakeFailure)
+	}
+
+	return nil
+}
+
+// resourceInstances returns the instances of the named resource, moving the pending and stopping ones
+// to their next state once their latency has passed.
+func (f *FakeCloud) resourceInstances(nameTag string) []*fakeInstance {
+	var instances []*fakeInstance
+
+	now := time.Now()
+	for _, inst := range f.instances {
+		if inst.NameTag != nameTag {
+			continue
+		}
+
+		if !now.Before(inst.Until) {
+			switch inst.State {
+			case fakeStatePending:
+				inst.State = fakeStateRunning
+			case fakeStateStopping:
+				inst.State = fakeStateStopped
+			}
+		}
+
+		instances = append(instances, inst)
+	}
+
+	return instances
+}
+
+// Start starts the stopped instances of the resource and locks them with tags.
+func (r *FakeResource) Start(startInput ResourceStartInput) error {
+	r.Cloud.mu.Lock()
+	defer r.Cloud.mu.Unlock()
+
+	instances := r.Cloud.resourceInstances(r.NameTag)
+	if err := checkLock(startInput.UID, fakeLockTags(instances), startInput.Preempts); err != nil {
+		return err
+	}
+
+	if err := r.Cloud.failure(FakeStart, r.NameTag); err != nil {
+		return err
+	}
+
+	until := time.Now().Add(r.Cloud.opts.StartLatency)
+	for _, inst := range instances {
+		if inst.State == fakeStateStopped || inst.State == fakeStateStopping {
+			inst.State, inst.Until = fakeStatePending, until
+		}
+
+		inst.Tags[lockedByTag] = startInput.UID
+		inst.Tags[lockedUntilTag] = startInput.EndAt
+	}
+
+	return nil
+}
+
+// Stop stops the running instances of the resource and removes their lock tags.
+func (r *FakeResource) Stop(stopInput ResourceStopInput) error {
+	r.Cloud.mu.Lock()
+	defer r.Cloud.mu.Unlock()
+
+	instances := r.Cloud.resourceInstances(r.NameTag)
+	if err := checkLock(stopInput.UID, fakeLockTags(instances), ""); err != nil {
+		return err
+	}
+
+	if err := r.Cloud.failure(FakeStop, r.NameTag); err != nil {
+		return err
+	}
+
+	until := time.Now().Add(r.Cloud.opts.StopLatency)
+	for _, inst := range instances {
+		if inst.State == fakeStateRunning || inst.State == fakeStatePending {
+			inst.State, inst.Until = fakeStateStopping, until
+		}
+
+		delete(inst.Tags, lockedByTag)
+		delete(inst.Tags, lockedUntilTag)
+	}
+
+	return nil
+}
+
+// Status returns the current summary of the resource instances. Pending and stopping instances don't count as running.
+func (r *FakeResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	r.Cloud.mu.Lock()
+	defer r.Cloud.mu.Unlock()
+
+	if err := r.Cloud.failure(FakeStatus, r.NameTag); err != nil {
+		return rst, err
+	}
+
+	instances := r.Cloud.resourceInstances(r.NameTag)
+	for _, inst := range instances {
+		rst.Available++
+		if inst.State == fakeStateRunning {
+			rst.Running++
+		}
+	}
+
+	tags := fakeLockTags(instances)
+	rst.LockedBy, rst.LockedUntil = tags[lockedByTag], tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// GetNewResources compares the local cluster resources with the managed fake instances
+// and gives back a list of resources that need to be created on the cluster.
+func (m *FakeMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags := make(map[string]bool)
+
+	m.Cloud.mu.Lock()
+	for _, inst := range m.Cloud.instances {
+		if inst.Managed {
+			uniqueTags[inst.NameTag] = true
+		}
+	}
+	m.Cloud.mu.Unlock()
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// fakeLockTags returns the lock tags of the instances.
+// The instances of a resource are locked together, so the tags of any of them are the lock of the resource.
+func fakeLockTags(instances []*fakeInstance) map[string]string {
+	tags := make(map[string]string)
+	for _, inst := range instances {
+		for _, k := range []string{lockedByTag, lockedUntilTag} {
+			if v, ok := inst.Tags[k]; ok {
+				tags[k] = v
+			}
+		}
+	}
+	return tags
+}
+
+// AddManagedInstances adds the managed instances described by the spec, a list of resource names and instance counts
+// like "analytics=2,reporting=1". A name without a count gets a single instance. Resources are added in name order,
+// so that instance IDs are stable across restarts.
+func (f *FakeCloud) AddManagedInstances(spec string) error {
+	counts := make(map[string]int)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, count, ok := strings.Cut(part, "=")
+		if !ok {
+			count = "1"
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 1 || name == "" {
+			return fmt.Errorf("invalid fake instances %q, expected name=count", part)
+		}
+		counts[name] = n
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f.AddInstances(name, counts[name], true)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package clients
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeResourceStartStop(t *testing.T) {
+	cloud := NewFakeCloud(FakeCloudOptions{StartLatency: 50 * time.Millisecond})
+	cloud.AddInstances("analytics", 2, false)
+	cloud.AddInstances("reporting", 1, false)
+	resource := &FakeResource{NameTag: "analytics", Cloud: cloud}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 2, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() while starting = %+v, want %+v", rst, want)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want.Running = 2
+	if rst != want {
+		t.Errorf("Status() after the start latency = %+v, want %+v", rst, want)
+	}
+
+	var locked *LockedError
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); !errors.As(err, &locked) || locked.LockedBy != "alice" {
+		t.Errorf("Stop() by another user error = %v, want a LockedError while the resource is locked by alice", err)
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 2}
+	if rst != want {
+		t.Errorf("Status() after Stop() = %+v, want %+v", rst, want)
+	}
+
+	other, err := (&FakeResource{NameTag: "reporting", Cloud: cloud}).Status()
+	if err != nil || other.Running != 0 {
+		t.Errorf("Status() of another resource = %+v, %v, want no running instances", other, err)
+	}
+}
+
+func TestFakeResourcePreemption(t *testing.T) {
+	cloud := NewFakeCloud(FakeCloudOptions{})
+	cloud.AddInstances("staging", 1, false)
+	resource := &FakeResource{NameTag: "staging", Cloud: cloud}
+	aliceEnd := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	bobEnd := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: aliceEnd}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	var locked *LockedError
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: bobEnd, Preempts: "carol"}); !errors.As(err, &locked) {
+		t.Errorf("Start() preempting another user error = %v, want a LockedError while the resource is locked by alice", err)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: bobEnd, Preempts: "alice"}); err != nil {
+		t.Fatalf("Start() preempting alice error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if rst.LockedBy != "bob" || rst.LockedUntil != bobEnd {
+		t.Errorf("Status() after the preemption = %+v, want the lock handed over to bob until %s", rst, bobEnd)
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); !errors.As(err, &locked) {
+		t.Errorf("Stop() by the preempted user error = %v, want a LockedError", err)
+	}
+}
+
+func TestFakeResourceInjectedFailures(t *testing.T) {
+	cloud := NewFakeCloud(FakeCloudOptions{})
+	cloud.AddInstances("analytics", 1, false)
+	resource := &FakeResource{NameTag: "analytics", Cloud: cloud}
+	injected := errors.New("quota exceeded")
+
+	cloud.InjectFailure(FakeStart, "analytics", injected)
+	if err := resource.Start(ResourceStartInput{UID: "alice"}); !errors.Is(err, injected) {
+		t.Errorf("Start() error = %v, want %v", err, injected)
+	}
+
+	cloud.InjectFailure(FakeStart, "analytics", nil)
+	if err := resource.Start(ResourceStartInput{UID: "alice"}); err != nil {
+		t.Errorf("Start() after clearing the failure error = %v", err)
+	}
+
+	always := NewFakeCloud(FakeCloudOptions{FailureRate: 1})
+	always.AddInstances("analytics", 1, false)
+	err := (&FakeResource{NameTag: "analytics", Cloud: always}).Stop(ResourceStopInput{UID: "alice"})
+	if !errors.Is(err, ErrFakeFailure) {
+		t.Errorf("Stop() with a failure rate of 1 error = %v, want %v", err, ErrFakeFailure)
+	}
+}
+
+func TestFakeCloudReset(t *testing.T) {
+	cloud := NewFakeCloud(FakeCloudOptions{})
+	cloud.AddInstances("analytics", 2, false)
+	cloud.InjectFailure(FakeStatus, "analytics", errors.New("throttled"))
+	resource := &FakeResource{NameTag: "analytics", Cloud: cloud}
+
+	cloud.Reset()
+	cloud.AddInstances("analytics", 1, false)
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() after Reset() error = %v", err)
+	}
+	if rst.Available != 1 {
+		t.Errorf("Status() after Reset() = %+v, want only the instance added since", rst)
+	}
+}
+
+func TestFakeMonitorGetNewResources(t *testing.T) {
+	cloud := NewFakeCloud(FakeCloudOptions{})
+	if err := cloud.AddManagedInstances("analytics=2, reporting"); err != nil {
+		t.Fatalf("AddManagedInstances() error = %v", err)
+	}
+	cloud.AddInstances("unmanaged", 1, false)
+	monitor := &FakeMonitor{Type: TypeFake, Cloud: cloud}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+
+	if err := cloud.AddManagedInstances("analytics=none"); err == nil {
+		t.Error("AddManagedInstances() with an invalid count should fail")
+	}
+}
@@ -0,0 +1,288 @@
+package clients
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+const gceStatusRunning = "RUNNING"
+
+// GCEClient is a Compute Engine client bound to the project whose instances it manages.
+type GCEClient struct {
+	Service *compute.Service
+	Project string
+}
+
+// GCEResource represents a collection of Compute Engine instances grouped by a common "resource-booking-application" label.
+type GCEResource struct {
+	NameTag string
+	Client  *GCEClient
+}
+
+type GCEMonitor struct {
+	Type   string
+	Client *GCEClient
+}
+
+type gceInstanceDetails struct {
+	Instances []*compute.Instance
+	Tags      map[string]string
+}
+
+var gceCtx = context.Background()
+
+// NewGCEClient creates a Compute Engine client for the given project.
+// Without options, it authenticates with the application default credentials.
+func NewGCEClient(project string, opts ...option.ClientOption) (*GCEClient, error) {
+	svc, err := compute.NewService(gceCtx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create Compute Engine client: %w", err)
+	}
+
+	return &GCEClient{Service: svc, Project: project}, nil
+}
+
+// Start makes a call through the Compute Engine client to start the instances of the resource and locks them with labels and metadata.
+func (r *GCEResource) Start(startInput ResourceStartInput) error {
+	instances, err := r.getInstanceDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(startInput.UID, instances.Tags, startInput.Preempts); err != nil {
+		return err
+	}
+
+	endAt, err := time.Parse(time.RFC3339, startInput.EndAt)
+	if err != nil {
+		return err
+	}
+
+	for _, inst := range instances.Instances {
+		_, err = r.Client.Service.Instances.Start(r.Client.Project, path.Base(inst.Zone), inst.Name).Context(gceCtx).Do()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.setLabels(instances.Instances, map[string]string{
+		lockedByTag:    gceLockLabel(startInput.UID),
+		lockedUntilTag: strconv.FormatInt(endAt.Unix(), 10),
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.setMetadata(instances.Instances, map[string]string{lockedByTag: startInput.UID})
+}
+
+// Stop makes a call through the Compute Engine client to stop the instances of the resource and removes their lock labels and metadata.
+func (r *GCEResource) Stop(stopInput ResourceStopInput) error {
+	instances, err := r.getInstanceDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(stopInput.UID, instances.Tags, ""); err != nil {
+		return err
+	}
+
+	for _, inst := range instances.Instances {
+		_, err = r.Client.Service.Instances.Stop(r.Client.Project, path.Base(inst.Zone), inst.Name).Context(gceCtx).Do()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.setLabels(instances.Instances, map[string]string{
+		lockedByTag:    "",
+		lockedUntilTag: "",
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.setMetadata(instances.Instances, map[string]string{lockedByTag: ""})
+}
+
+// Status returns the current summary of the resource instance statuses.
+func (r *GCEResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	instances, err := r.getInstanceDetails(r.NameTag)
+	if err != nil {
+		return rst, err
+	}
+
+	for _, inst := range instances.Instances {
+		rst.Available++
+		if inst.Status == gceStatusRunning {
+			rst.Running++
+		}
+	}
+
+	rst.LockedBy, rst.LockedUntil = instances.Tags[lockedByTag], instances.Tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// setLabels merges the given labels into the labels of each instance. Labels with an empty value are removed.
+func (r *GCEResource) setLabels(instances []*compute.Instance, labels map[string]string) error {
+	for _, inst := range instances {
+		merged := make(map[string]string, len(inst.Labels)+len(labels))
+		for k, v := range inst.Labels {
+			merged[k] = v
+		}
+		for k, v := range labels {
+			if v == "" {
+				delete(merged, k)
+			} else {
+				merged[k] = v
+			}
+		}
+
+		req := &compute.InstancesSetLabelsRequest{Labels: merged, LabelFingerprint: inst.LabelFingerprint}
+		_, err := r.Client.Service.Instances.SetLabels(r.Client.Project, path.Base(inst.Zone), inst.Name, req).Context(gceCtx).Do()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setMetadata merges the given items into the metadata of each instance. Items with an empty value are removed.
+func (r *GCEResource) setMetadata(instances []*compute.Instance, items map[string]string) error {
+	for _, inst := range instances {
+		metadata := &compute.Metadata{}
+		if inst.Metadata != nil {
+			metadata.Fingerprint = inst.Metadata.Fingerprint
+			for _, item := range inst.Metadata.Items {
+				if _, ok := items[item.Key]; !ok {
+					metadata.Items = append(metadata.Items, item)
+				}
+			}
+		}
+		for k, v := range items {
+			if v != "" {
+				metadata.Items = append(metadata.Items, &compute.MetadataItems{Key: k, Value: &v})
+			}
+		}
+
+		_, err := r.Client.Service.Instances.SetMetadata(r.Client.Project, path.Base(inst.Zone), inst.Name, metadata).Context(gceCtx).Do()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getInstanceDetails returns the instances labeled with the given name tag, along with their lock labels.
+// Label values can't hold an RFC3339 date, so the lock expiry is stored as a unix timestamp and converted back here.
+// They can't hold any user ID either, so the lock holder is read from the instance metadata, and the label only has its hash.
+func (r *GCEResource) getInstanceDetails(nameTag string) (gceInstanceDetails, error) {
+	details := gceInstanceDetails{Tags: make(map[string]string)}
+
+	instances, err := listGCEInstances(r.Client, fmt.Sprintf("labels.%s = %q", defaultTagKey, nameTag))
+	if err != nil {
+		return details, err
+	}
+	details.Instances = instances
+
+	// The instances of a resource are locked together, so the labels of any of them are the lock of the resource
+	for _, inst := range instances {
+		if _, ok := inst.Labels[lockedByTag]; ok {
+			details.Tags[lockedByTag] = gceMetadataValue(inst, lockedByTag)
+		}
+
+		if lockedUntil, ok := inst.Labels[lockedUntilTag]; ok {
+			sec, err := strconv.ParseInt(lockedUntil, 10, 64)
+			if err != nil {
+				return details, err
+			}
+			details.Tags[lockedUntilTag] = time.Unix(sec, 0).UTC().Format(time.RFC3339)
+		}
+
+		if len(details.Tags) == 2 {
+			break
+		}
+	}
+
+	return details, nil
+}
+
+// GetNewResources compares the local cluster resources with the ones returned from Compute Engine
+// and gives back a list of resources that need to be created on the cluster.
+func (m *GCEMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags, err := getUniqueGCELabels(m.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// getUniqueGCELabels collects the resource names of all the instances that are marked as managed by the operator.
+func getUniqueGCELabels(gceClient *GCEClient) (map[string]bool, error) {
+	tagMap := make(map[string]bool)
+
+	instances, err := listGCEInstances(gceClient, fmt.Sprintf("labels.%s = %q", resourceMonitorTagKey, "true"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inst := range instances {
+		if v, ok := inst.Labels[defaultTagKey]; ok {
+			tagMap[v] = true
+		}
+	}
+
+	return tagMap, nil
+}
+
+// listGCEInstances returns the instances across all zones of the project that match the given filter.
+func listGCEInstances(gceClient *GCEClient, filter string) ([]*compute.Instance, error) {
+	var instances []*compute.Instance
+	err := gceClient.Service.Instances.AggregatedList(gceClient.Project).Filter(filter).Pages(gceCtx, func(page *compute.InstanceAggregatedList) error {
+		for _, scoped := range page.Items {
+			instances = append(instances, scoped.Instances...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+// gceLockLabel turns a user ID into a valid Compute Engine label value, that stays the same for the same user.
+func gceLockLabel(uid string) string {
+	sum := sha256.Sum256([]byte(uid))
+	return hex.EncodeToString(sum[:16])
+}
+
+// gceMetadataValue returns the value of the metadata item of the instance with the given key.
+func gceMetadataValue(inst *compute.Instance, key string) string {
+	if inst.Metadata == nil {
+		return ""
+	}
+	for _, item := range inst.Metadata.Items {
+		if item.Key == key && item.Value != nil {
+			return *item.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,223 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/option"
+)
+
+// fakeCompute is a minimal in-memory stand-in for the Compute Engine API, serving the calls the GCE backend makes.
+type fakeCompute struct {
+	mu        sync.Mutex
+	instances map[string]*compute.Instance
+}
+
+func (f *fakeCompute) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/aggregated/instances"):
+		var matching []*compute.Instance
+		for _, inst := range f.instances {
+			if gceFilterMatches(req.URL.Query().Get("filter"), inst.Labels) {
+				matching = append(matching, inst)
+			}
+		}
+		list := compute.InstanceAggregatedList{Items: map[string]compute.InstancesScopedList{
+			"zones/europe-west1-b": {Instances: matching},
+		}}
+		_ = json.NewEncoder(w).Encode(list)
+		return
+	case req.Method == http.MethodPost && len(parts) >= 2:
+		inst, ok := f.instances[parts[len(parts)-2]]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+
+		switch parts[len(parts)-1] {
+		case "start":
+			inst.Status = "RUNNING"
+		case "stop":
+			inst.Status = "TERMINATED"
+		case "setLabels":
+			var body compute.InstancesSetLabelsRequest
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if body.LabelFingerprint != inst.LabelFingerprint {
+				http.Error(w, "label fingerprint mismatch", http.StatusPreconditionFailed)
+				return
+			}
+			inst.Labels = body.Labels
+			inst.LabelFingerprint += "x"
+		case "setMetadata":
+			var body compute.Metadata
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if inst.Metadata != nil && body.Fingerprint != inst.Metadata.Fingerprint {
+				http.Error(w, "metadata fingerprint mismatch", http.StatusPreconditionFailed)
+				return
+			}
+			body.Fingerprint += "x"
+			inst.Metadata = &body
+		}
+		_ = json.NewEncoder(w).Encode(compute.Operation{Status: "DONE"})
+		return
+	}
+
+	http.NotFound(w, req)
+}
+
+// gceFilterMatches understands the single `labels.key = "value"` filters used by the GCE backend.
+func gceFilterMatches(filter string, labels map[string]string) bool {
+	key, value, _ := strings.Cut(strings.TrimPrefix(filter, "labels."), " = ")
+	return labels[key] == strings.Trim(value, `"`)
+}
+
+func setupFakeCompute(t *testing.T, instances ...*compute.Instance) (*fakeCompute, *GCEClient) {
+	fake := &fakeCompute{instances: make(map[string]*compute.Instance)}
+	for _, inst := range instances {
+		fake.instances[inst.Name] = inst
+	}
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client, err := NewGCEClient("test-project", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, client
+}
+
+func newGCEInstance(name, status string, labels map[string]string) *compute.Instance {
+	return &compute.Instance{
+		Name:             name,
+		Status:           status,
+		Zone:             "https://www.googleapis.com/compute/v1/projects/test-project/zones/europe-west1-b",
+		Labels:           labels,
+		LabelFingerprint: "fp",
+	}
+}
+
+func TestGCEResourceStartStop(t *testing.T) {
+	fake, client := setupFakeCompute(t,
+		newGCEInstance("analytics-1", "TERMINATED", map[string]string{defaultTagKey: "analytics"}),
+		newGCEInstance("analytics-2", "TERMINATED", map[string]string{defaultTagKey: "analytics"}),
+		newGCEInstance("reporting-1", "TERMINATED", map[string]string{defaultTagKey: "reporting"}),
+	)
+	resource := &GCEResource{NameTag: "analytics", Client: client}
+	endAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "Alice@example.com", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 2, Running: 2, LockedBy: "Alice@example.com", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+	if got := fake.instances["reporting-1"].Status; got != "TERMINATED" {
+		t.Errorf("instance of another resource has status %s, want TERMINATED", got)
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); err == nil {
+		t.Error("Stop() by another user should fail while the resource is locked")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "Alice@example.com"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 2}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+	if labels := fake.instances["analytics-1"].Labels; labels[defaultTagKey] != "analytics" || len(labels) != 1 {
+		t.Errorf("labels after Stop() = %v, want only the %s label", labels, defaultTagKey)
+	}
+	if lockedBy := gceMetadataValue(fake.instances["analytics-1"], lockedByTag); lockedBy != "" {
+		t.Errorf("%s metadata after Stop() = %q, want none", lockedByTag, lockedBy)
+	}
+}
+
+func TestGCEResourceLock(t *testing.T) {
+	fake, client := setupFakeCompute(t,
+		newGCEInstance("analytics-1", "TERMINATED", map[string]string{defaultTagKey: "analytics"}),
+	)
+	resource := &GCEResource{NameTag: "analytics", Client: client}
+	endAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+	extendedEndAt := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second).Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "Alice.Smith@example.com", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if got := fake.instances["analytics-1"].Labels[lockedByTag]; got != gceLockLabel("Alice.Smith@example.com") {
+		t.Errorf("%s label = %q, want the hash of the user ID", lockedByTag, got)
+	}
+
+	// A user whose ID sanitizes to the same label value must not share the lock
+	var lockedErr *LockedError
+	if err := resource.Start(ResourceStartInput{UID: "alice_smith_example_com", EndAt: endAt}); !errors.As(err, &lockedErr) || lockedErr.LockedBy != "Alice.Smith@example.com" {
+		t.Errorf("Start() by another user error = %v, want a lock of Alice.Smith@example.com", err)
+	}
+
+	// The owner locks the running resource again, like when the booking is extended
+	if err := resource.Start(ResourceStartInput{UID: "Alice.Smith@example.com", EndAt: extendedEndAt}); err != nil {
+		t.Fatalf("Start() by the lock holder error = %v", err)
+	}
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if rst.LockedBy != "Alice.Smith@example.com" || rst.LockedUntil != extendedEndAt {
+		t.Errorf("Status() = %+v, want a lock of Alice.Smith@example.com until %s", rst, extendedEndAt)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "Bob@example.com", EndAt: endAt, Preempts: "Alice.Smith@example.com"}); err != nil {
+		t.Fatalf("Start() preempting the lock holder error = %v", err)
+	}
+	if err := resource.Stop(ResourceStopInput{UID: "Bob@example.com"}); err != nil {
+		t.Fatalf("Stop() by the new lock holder error = %v", err)
+	}
+}
+
+func TestGCEMonitorGetNewResources(t *testing.T) {
+	_, client := setupFakeCompute(t,
+		newGCEInstance("analytics-1", "RUNNING", map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"}),
+		newGCEInstance("reporting-1", "RUNNING", map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"}),
+		newGCEInstance("unmanaged-1", "RUNNING", map[string]string{defaultTagKey: "unmanaged"}),
+	)
+	monitor := &GCEMonitor{Type: TypeGCE, Client: client}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
@@ -0,0 +1,232 @@
+package clients
+
+import (
+	"context"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// replicasAnnotation stores the replica count a workload had before it was scaled to zero.
+const replicasAnnotation string = "resource-booking-replicas"
+
+// K8sWorkloadResource represents a collection of Deployments and StatefulSets grouped by a common "resource-booking-application" label.
+// The workloads are looked up in all namespaces, so the client should not be limited to the namespace of the operator.
+type K8sWorkloadResource struct {
+	NameTag string
+	Client  client.Client
+}
+
+type K8sWorkloadMonitor struct {
+	Type   string
+	Client client.Client
+}
+
+// workload wraps the fields that Deployments and StatefulSets have in common.
+type workload struct {
+	Object   client.Object
+	Replicas **int32
+	Ready    int32
+}
+
+type workloadDetails struct {
+	Workloads []workload
+	Tags      map[string]string
+}
+
+var workloadCtx = context.Background()
+
+// Start restores the replica counts the workloads of the resource had before they were stopped, and locks them with annotations.
+func (r *K8sWorkloadResource) Start(startInput ResourceStartInput) error {
+	workloads, err := r.getWorkloadDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(startInput.UID, workloads.Tags, startInput.Preempts); err != nil {
+		return err
+	}
+
+	for _, w := range workloads.Workloads {
+		err := r.patch(w, func(annotations map[string]string) error {
+			if v, ok := annotations[replicasAnnotation]; ok {
+				replicas, err := strconv.ParseInt(v, 10, 32)
+				if err != nil {
+					return err
+				}
+
+				count := int32(replicas)
+				*w.Replicas = &count
+				delete(annotations, replicasAnnotation)
+			}
+
+			annotations[lockedByTag] = startInput.UID
+			annotations[lockedUntilTag] = startInput.EndAt
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop scales the workloads of the resource to zero, remembering their replica counts in an annotation, and removes the lock annotations.
+func (r *K8sWorkloadResource) Stop(stopInput ResourceStopInput) error {
+	workloads, err := r.getWorkloadDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(stopInput.UID, workloads.Tags, ""); err != nil {
+		return err
+	}
+
+	for _, w := range workloads.Workloads {
+		err := r.patch(w, func(annotations map[string]string) error {
+			if replicas := workloadReplicas(w); replicas > 0 {
+				annotations[replicasAnnotation] = strconv.Itoa(int(replicas))
+			}
+
+			var zero int32
+			*w.Replicas = &zero
+			delete(annotations, lockedByTag)
+			delete(annotations, lockedUntilTag)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status returns the ready replicas of the resource workloads as running, and their desired replicas as available.
+// The desired replicas of a stopped workload are the ones it will be scaled back to on start.
+func (r *K8sWorkloadResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	workloads, err := r.getWorkloadDetails(r.NameTag)
+	if err != nil {
+		return rst, err
+	}
+
+	for _, w := range workloads.Workloads {
+		desired := workloadReplicas(w)
+		if v, ok := w.Object.GetAnnotations()[replicasAnnotation]; ok && desired == 0 {
+			replicas, err := strconv.ParseInt(v, 10, 32)
+			if err != nil {
+				return rst, err
+			}
+			desired = int32(replicas)
+		}
+
+		rst.Available += int(desired)
+		rst.Running += int(w.Ready)
+	}
+
+	rst.LockedBy, rst.LockedUntil = workloads.Tags[lockedByTag], workloads.Tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// patch applies the changes made by mutate to the workload and its annotations.
+func (r *K8sWorkloadResource) patch(w workload, mutate func(annotations map[string]string) error) error {
+	base := w.Object.DeepCopyObject().(client.Object)
+
+	annotations := w.Object.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	if err := mutate(annotations); err != nil {
+		return err
+	}
+	w.Object.SetAnnotations(annotations)
+
+	return r.Client.Patch(workloadCtx, w.Object, client.MergeFrom(base))
+}
+
+// getWorkloadDetails returns the workloads labeled with the given name tag, along with their lock annotations.
+func (r *K8sWorkloadResource) getWorkloadDetails(nameTag string) (workloadDetails, error) {
+	details := workloadDetails{Tags: make(map[string]string)}
+
+	workloads, err := listWorkloads(r.Client, client.MatchingLabels{defaultTagKey: nameTag})
+	if err != nil {
+		return details, err
+	}
+	details.Workloads = workloads
+
+	// The workloads of a resource are locked together, so the annotations of any of them are the lock of the resource
+	for _, w := range workloads {
+		for _, k := range []string{lockedByTag, lockedUntilTag} {
+			if v, ok := w.Object.GetAnnotations()[k]; ok {
+				details.Tags[k] = v
+			}
+		}
+
+		if len(details.Tags) == 2 {
+			break
+		}
+	}
+
+	return details, nil
+}
+
+// GetNewResources compares the local cluster resources with the labeled workloads
+// and gives back a list of resources that need to be created on the cluster.
+func (m *K8sWorkloadMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags := make(map[string]bool)
+
+	workloads, err := listWorkloads(m.Client, client.MatchingLabels{resourceMonitorTagKey: "true"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range workloads {
+		if v, ok := w.Object.GetLabels()[defaultTagKey]; ok {
+			uniqueTags[v] = true
+		}
+	}
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// listWorkloads returns the Deployments and StatefulSets of all namespaces that match the given labels.
+func listWorkloads(c client.Client, labels client.MatchingLabels) ([]workload, error) {
+	var workloads []workload
+
+	var deployments appsv1.DeploymentList
+	if err := c.List(workloadCtx, &deployments, labels); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		workloads = append(workloads, workload{Object: d, Replicas: &d.Spec.Replicas, Ready: d.Status.ReadyReplicas})
+	}
+
+	var statefulSets appsv1.StatefulSetList
+	if err := c.List(workloadCtx, &statefulSets, labels); err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		workloads = append(workloads, workload{Object: s, Replicas: &s.Spec.Replicas, Ready: s.Status.ReadyReplicas})
+	}
+
+	return workloads, nil
+}
+
+// workloadReplicas returns the desired replicas of the workload. Kubernetes defaults them to one when unset.
+func workloadReplicas(w workload) int32 {
+	if *w.Replicas == nil {
+		return 1
+	}
+	return **w.Replicas
+}
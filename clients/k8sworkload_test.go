@@ -0,0 +1,194 @@
+package clients
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newWorkloadClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := appsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func newDeployment(namespace, name string, replicas *int32, labels, annotations map[string]string) *appsv1.Deployment {
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: annotations},
+		Spec:       appsv1.DeploymentSpec{Replicas: replicas},
+	}
+	if replicas != nil {
+		d.Status.ReadyReplicas = *replicas
+	}
+	return d
+}
+
+func newStatefulSet(namespace, name string, replicas int32, labels, annotations map[string]string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels, Annotations: annotations},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: replicas},
+	}
+}
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
+
+func TestK8sWorkloadResourceStartStop(t *testing.T) {
+	labels := map[string]string{defaultTagKey: "analytics"}
+	c := newWorkloadClient(t,
+		newDeployment("web", "analytics-api", int32Ptr(3), labels, nil),
+		newDeployment("web", "analytics-cron", nil, labels, nil),
+		newStatefulSet("data", "analytics-db", 2, labels, nil),
+		newStatefulSet("data", "reporting-db", 1, map[string]string{defaultTagKey: "reporting"}, nil),
+	)
+	resource := &K8sWorkloadResource{NameTag: "analytics", Client: c}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	var api, cron appsv1.Deployment
+	var db, reporting appsv1.StatefulSet
+	get := func() {
+		t.Helper()
+		for key, obj := range map[client.ObjectKey]client.Object{
+			{Namespace: "web", Name: "analytics-api"}:  &api,
+			{Namespace: "web", Name: "analytics-cron"}: &cron,
+			{Namespace: "data", Name: "analytics-db"}:  &db,
+			{Namespace: "data", Name: "reporting-db"}:  &reporting,
+		} {
+			if err := c.Get(workloadCtx, key, obj); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	get()
+	for name, w := range map[string]struct {
+		replicas   *int32
+		annotation string
+	}{
+		"analytics-api":  {api.Spec.Replicas, api.Annotations[replicasAnnotation]},
+		"analytics-cron": {cron.Spec.Replicas, cron.Annotations[replicasAnnotation]},
+		"analytics-db":   {db.Spec.Replicas, db.Annotations[replicasAnnotation]},
+	} {
+		if w.replicas == nil || *w.replicas != 0 {
+			t.Errorf("%s isn't scaled to zero after Stop()", name)
+		}
+		if w.annotation == "" {
+			t.Errorf("%s doesn't remember its replicas after Stop()", name)
+		}
+	}
+	// Unset replicas default to one
+	if got := cron.Annotations[replicasAnnotation]; got != "1" {
+		t.Errorf("%s annotation of a Deployment without replicas = %q, want 1", replicasAnnotation, got)
+	}
+	if got := db.Annotations[replicasAnnotation]; got != "2" {
+		t.Errorf("%s annotation of the StatefulSet = %q, want 2", replicasAnnotation, got)
+	}
+	if *reporting.Spec.Replicas != 1 {
+		t.Errorf("StatefulSet of another resource has %d replicas, want 1", *reporting.Spec.Replicas)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	// Stopped workloads count the replicas they are scaled back to on start as available
+	if rst.Available != 6 || rst.LockedBy != "" {
+		t.Errorf("Status() of the stopped resource = %+v, want 6 available and no lock", rst)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	get()
+	if *api.Spec.Replicas != 3 || *cron.Spec.Replicas != 1 || *db.Spec.Replicas != 2 {
+		t.Errorf("replicas after Start() = %d/%d/%d, want 3/1/2", *api.Spec.Replicas, *cron.Spec.Replicas, *db.Spec.Replicas)
+	}
+	for _, annotations := range []map[string]string{api.Annotations, cron.Annotations, db.Annotations} {
+		if _, ok := annotations[replicasAnnotation]; ok {
+			t.Errorf("%s annotation is left after Start()", replicasAnnotation)
+		}
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 6, Running: 5, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() of the started resource = %+v, want %+v", rst, want)
+	}
+}
+
+func TestK8sWorkloadResourceLock(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	labels := map[string]string{defaultTagKey: "analytics"}
+	lock := map[string]string{lockedByTag: "alice", lockedUntilTag: lockedUntil}
+	c := newWorkloadClient(t,
+		newDeployment("web", "analytics-api", int32Ptr(2), labels, lock),
+		newStatefulSet("data", "analytics-db", 1, labels, lock),
+	)
+	resource := &K8sWorkloadResource{NameTag: "analytics", Client: c}
+	endAt := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	var lockedErr *LockedError
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt}); !errors.As(err, &lockedErr) || lockedErr.LockedBy != "alice" {
+		t.Errorf("Start() by another user error = %v, want a lock of alice", err)
+	}
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); !errors.As(err, &lockedErr) {
+		t.Errorf("Stop() by another user error = %v, want a LockedError", err)
+	}
+
+	var db appsv1.StatefulSet
+	if err := c.Get(workloadCtx, client.ObjectKey{Namespace: "data", Name: "analytics-db"}, &db); err != nil {
+		t.Fatal(err)
+	}
+	if *db.Spec.Replicas != 1 {
+		t.Errorf("replicas of the locked StatefulSet = %d, want 1", *db.Spec.Replicas)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt, Preempts: "alice"}); err != nil {
+		t.Fatalf("Start() preempting the lock holder error = %v", err)
+	}
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if rst.LockedBy != "bob" || rst.LockedUntil != endAt {
+		t.Errorf("Status() after the preemption = %+v, want a lock of bob until %s", rst, endAt)
+	}
+}
+
+func TestK8sWorkloadMonitorGetNewResources(t *testing.T) {
+	c := newWorkloadClient(t,
+		newDeployment("web", "analytics-api", int32Ptr(1), map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"}, nil),
+		newStatefulSet("data", "reporting-db", 1, map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"}, nil),
+		newDeployment("web", "unmanaged-api", int32Ptr(1), map[string]string{defaultTagKey: "unmanaged"}, nil),
+	)
+	monitor := &K8sWorkloadMonitor{Type: TypeK8sWorkload, Client: c}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
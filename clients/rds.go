@@ -2,54 +2,73 @@ package clients
 
 import (
 	"context"
-	"fmt"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/rds"
 	"github.com/aws/aws-sdk-go-v2/service/rds/types"
 )
 
 const StatusAvailable = "available"
 
+// rdsStatusStopped is the status of a stopped RDS instance, the only status an instance can be started from.
+const rdsStatusStopped = "stopped"
+
+// stoppedAtTag stores when the operator stopped an instance, to tell apart the instances that AWS started on its own.
+const stoppedAtTag string = "resource-booking-stopped-at"
+
+// rdsAutoStartAfter is how long AWS keeps an RDS instance stopped before starting it again automatically.
+const rdsAutoStartAfter = 7 * 24 * time.Hour
+
+// RDSAPI is the part of the RDS client that the RDS instance and cluster resources and monitors use.
+type RDSAPI interface {
+	DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error)
+	DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error)
+	ListTagsForResource(ctx context.Context, params *rds.ListTagsForResourceInput, optFns ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error)
+	StartDBInstance(ctx context.Context, params *rds.StartDBInstanceInput, optFns ...func(*rds.Options)) (*rds.StartDBInstanceOutput, error)
+	StopDBInstance(ctx context.Context, params *rds.StopDBInstanceInput, optFns ...func(*rds.Options)) (*rds.StopDBInstanceOutput, error)
+	StartDBCluster(ctx context.Context, params *rds.StartDBClusterInput, optFns ...func(*rds.Options)) (*rds.StartDBClusterOutput, error)
+	StopDBCluster(ctx context.Context, params *rds.StopDBClusterInput, optFns ...func(*rds.Options)) (*rds.StopDBClusterOutput, error)
+	AddTagsToResource(ctx context.Context, params *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error)
+	RemoveTagsFromResource(ctx context.Context, params *rds.RemoveTagsFromResourceInput, optFns ...func(*rds.Options)) (*rds.RemoveTagsFromResourceOutput, error)
+}
+
 type RDSResource struct {
 	NameTag string
+	Client  RDSAPI
 }
 
 type RDSMonitor struct {
-	Type string
+	Type   string
+	Client RDSAPI
 }
 
 type RDSInstanceDetails struct {
 	IDs           []string
+	Statuses      map[string]string
 	Tags          map[string]string
 	ResourceNames []string
 }
 
-var rdsClient *rds.Client
 var rdsCtx = context.Background()
 
-func init() {
-	cfg, err := config.LoadDefaultConfig(rdsCtx)
-	if err != nil {
-		fmt.Printf("unable to load SDK config for RDS: %v\n", err)
-		return
-	}
-	rdsClient = rds.NewFromConfig(cfg)
-}
-
 func (r *RDSResource) Start(startInput ResourceStartInput) error {
 	instances, err := r.getRDSInstanceDetails(r.NameTag)
 	if err != nil {
 		return err
 	}
 
-	if _, err = r.canManageRDS(startInput.UID, instances.Tags); err != nil {
+	if err = checkLock(startInput.UID, instances.Tags, startInput.Preempts); err != nil {
 		return err
 	}
 
+	// Instances that are already running are only locked again, like when a booking is extended
 	for _, dbInstance := range instances.IDs {
-		_, err = rdsClient.StartDBInstance(rdsCtx, &rds.StartDBInstanceInput{
+		if instances.Statuses[dbInstance] != rdsStatusStopped {
+			continue
+		}
+
+		_, err = r.Client.StartDBInstance(rdsCtx, &rds.StartDBInstanceInput{
 			DBInstanceIdentifier: &dbInstance,
 		})
 		if err != nil {
@@ -62,6 +81,11 @@ func (r *RDSResource) Start(startInput ResourceStartInput) error {
 		return err
 	}
 
+	err = r.removeRDSTags(instances.ResourceNames, stoppedAtTag)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -71,11 +95,11 @@ func (r *RDSResource) Stop(stopInput ResourceStopInput) error {
 		return err
 	}
 
-	if _, err = r.canManageRDS(stopInput.UID, instances.Tags); err != nil {
+	if err = checkLock(stopInput.UID, instances.Tags, ""); err != nil {
 		return err
 	}
 	for _, instance := range instances.IDs {
-		_, err = rdsClient.StopDBInstance(rdsCtx, &rds.StopDBInstanceInput{
+		_, err = r.Client.StopDBInstance(rdsCtx, &rds.StopDBInstanceInput{
 			DBInstanceIdentifier: &instance,
 		})
 		if err != nil {
@@ -88,9 +112,69 @@ func (r *RDSResource) Stop(stopInput ResourceStopInput) error {
 		return err
 	}
 
+	err = r.markStoppedRDS(time.Now(), instances.ResourceNames)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// StopAutoStarted stops the instances of the resource that AWS started on its own, after they had been stopped
+// by the operator for seven days. Instances that are still starting can't be stopped yet, and are left for a later call.
+// It returns the identifiers of the stopped instances.
+func (r *RDSResource) StopAutoStarted() ([]string, error) {
+	instances, err := r.getRDSInstancesByTag(r.NameTag)
+	if err != nil {
+		return nil, err
+	}
+
+	var stopped []string
+	now := time.Now()
+	for _, instance := range instances {
+		if aws.ToString(instance.DBInstanceStatus) != StatusAvailable || !rdsAutoStarted(instance, now) {
+			continue
+		}
+
+		_, err = r.Client.StopDBInstance(rdsCtx, &rds.StopDBInstanceInput{
+			DBInstanceIdentifier: instance.DBInstanceIdentifier,
+		})
+		if err != nil {
+			return stopped, err
+		}
+
+		err = r.markStoppedRDS(now, []string{*instance.DBInstanceArn})
+		if err != nil {
+			return stopped, err
+		}
+
+		stopped = append(stopped, *instance.DBInstanceIdentifier)
+	}
+
+	return stopped, nil
+}
+
+// rdsAutoStarted reports whether the running instance was started by AWS. The operator removes the stopped at tag
+// when it starts an instance itself, and so does not lock an instance that it didn't start.
+func rdsAutoStarted(instance types.DBInstance, now time.Time) bool {
+	var stoppedAt string
+	for _, t := range instance.TagList {
+		switch *t.Key {
+		case lockedByTag:
+			return false
+		case stoppedAtTag:
+			stoppedAt = aws.ToString(t.Value)
+		}
+	}
+
+	d, err := time.Parse(time.RFC3339, stoppedAt)
+	if err != nil {
+		return false
+	}
+
+	return !now.Before(d.Add(rdsAutoStartAfter))
+}
+
 func (r *RDSResource) Status() (ResourceStatusOutput, error) {
 	var rst ResourceStatusOutput
 
@@ -118,7 +202,7 @@ func (r *RDSResource) Status() (ResourceStatusOutput, error) {
 func (r *RDSResource) getRDSInstancesByTag(nameTag string) ([]types.DBInstance, error) {
 
 	// Retrieve the list of all DB instances
-	instances, err := rdsClient.DescribeDBInstances(rdsCtx, nil)
+	instances, err := r.Client.DescribeDBInstances(rdsCtx, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -126,10 +210,15 @@ func (r *RDSResource) getRDSInstancesByTag(nameTag string) ([]types.DBInstance,
 	// Filter the instances based on the specified tag key and value
 	var filteredInstances []types.DBInstance
 	for _, instance := range instances.DBInstances {
+		// Members of DB clusters can only be started and stopped along with their cluster, see RDSClusterResource
+		if instance.DBClusterIdentifier != nil {
+			continue
+		}
+
 		input := &rds.ListTagsForResourceInput{
 			ResourceName: instance.DBInstanceArn,
 		}
-		result, err := rdsClient.ListTagsForResource(rdsCtx, input)
+		result, err := r.Client.ListTagsForResource(rdsCtx, input)
 		if err != nil {
 			return nil, err
 		}
@@ -144,7 +233,7 @@ func (r *RDSResource) getRDSInstancesByTag(nameTag string) ([]types.DBInstance,
 }
 
 func (m *RDSMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
-	uniqueTags, err := getUniqueRDSTags()
+	uniqueTags, err := getUniqueRDSTags(m.Client)
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +244,7 @@ func (m *RDSMonitor) GetNewResources(clusterResources map[string]bool) ([]string
 	return nonMatchingTags, nil
 }
 
-func getUniqueRDSTags() (map[string]bool, error) {
+func getUniqueRDSTags(rdsClient RDSAPI) (map[string]bool, error) {
 	tagMap := make(map[string]bool)
 
 	instances, err := rdsClient.DescribeDBInstances(rdsCtx, nil)
@@ -197,7 +286,7 @@ func getUniqueRDSTags() (map[string]bool, error) {
 // resource-booking-locked-until - Date time until the instance is available again. The endAt of the booking.
 func (r *RDSResource) lockRDS(uid string, endAt string, resourceNames []string) error {
 	for _, resourceName := range resourceNames {
-		_, err := rdsClient.AddTagsToResource(rdsCtx, &rds.AddTagsToResourceInput{
+		_, err := r.Client.AddTagsToResource(rdsCtx, &rds.AddTagsToResourceInput{
 			ResourceName: &resourceName,
 			Tags: []types.Tag{
 				{Key: &lockedByTag, Value: &uid},
@@ -216,7 +305,7 @@ func (r *RDSResource) lockRDS(uid string, endAt string, resourceNames []string)
 // unlock removes the locking tags, freeing the resource to other users.
 func (r *RDSResource) unlockRDS(resourceNames []string) error {
 	for _, resourceName := range resourceNames {
-		_, err := rdsClient.RemoveTagsFromResource(rdsCtx, &rds.RemoveTagsFromResourceInput{
+		_, err := r.Client.RemoveTagsFromResource(rdsCtx, &rds.RemoveTagsFromResourceInput{
 			ResourceName: &resourceName,
 			TagKeys:      []string{lockedByTag, lockedUntilTag},
 		})
@@ -229,25 +318,41 @@ func (r *RDSResource) unlockRDS(resourceNames []string) error {
 	return nil
 }
 
-func (r *RDSResource) canManageRDS(uid string, instanceTags map[string]string) (bool, error) {
-	if _, ok := instanceTags[lockedByTag]; ok && instanceTags[lockedUntilTag] != "" {
-		d, err := time.Parse(time.RFC3339, instanceTags[lockedUntilTag])
+// markStoppedRDS tags the resource instances with the time they were stopped at.
+func (r *RDSResource) markStoppedRDS(stoppedAt time.Time, resourceNames []string) error {
+	value := stoppedAt.UTC().Format(time.RFC3339)
+	for _, resourceName := range resourceNames {
+		_, err := r.Client.AddTagsToResource(rdsCtx, &rds.AddTagsToResourceInput{
+			ResourceName: &resourceName,
+			Tags:         []types.Tag{{Key: aws.String(stoppedAtTag), Value: &value}},
+		})
+
 		if err != nil {
-			return false, err
+			return err
 		}
+	}
+
+	return nil
+}
 
-		if instanceTags[lockedByTag] != uid && time.Now().Before(d) {
-			m := "Resource is locked by %s. The lock expires at %s."
-			err = fmt.Errorf(m, instanceTags[lockedByTag], instanceTags[lockedUntilTag])
-			return false, err
+// removeRDSTags removes the tags with the given keys from the resource instances.
+func (r *RDSResource) removeRDSTags(resourceNames []string, keys ...string) error {
+	for _, resourceName := range resourceNames {
+		_, err := r.Client.RemoveTagsFromResource(rdsCtx, &rds.RemoveTagsFromResourceInput{
+			ResourceName: &resourceName,
+			TagKeys:      keys,
+		})
+
+		if err != nil {
+			return err
 		}
 	}
 
-	return true, nil
+	return nil
 }
 
 func (r *RDSResource) getRDSInstanceDetails(nameTag string) (RDSInstanceDetails, error) {
-	details := RDSInstanceDetails{Tags: make(map[string]string)}
+	details := RDSInstanceDetails{Tags: make(map[string]string), Statuses: make(map[string]string)}
 
 	var instanceTagList []types.Tag
 	resp, err := r.getRDSInstancesByTag(nameTag)
@@ -257,11 +362,12 @@ func (r *RDSResource) getRDSInstanceDetails(nameTag string) (RDSInstanceDetails,
 
 	for _, instance := range resp {
 		details.IDs = append(details.IDs, *instance.DBInstanceIdentifier)
+		details.Statuses[*instance.DBInstanceIdentifier] = aws.ToString(instance.DBInstanceStatus)
 		details.ResourceNames = append(details.ResourceNames, *instance.DBInstanceArn)
 		instanceTagList = append(instanceTagList, instance.TagList...)
 	}
 
-	// For now Don't care about the edge case where two instances might theoretically have different lock tags
+	// The instances of a resource are locked together, so the tags of any of them are the lock of the resource
 	for _, v := range instanceTagList {
 		if *v.Key == lockedByTag || *v.Key == lockedUntilTag {
 			details.Tags[*v.Key] = *v.Value
@@ -0,0 +1,364 @@
+package clients
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// fakeRDS keeps the DB instances and clusters in memory and implements the RDS calls the RDS backends make.
+// Instances and clusters reach their target status as soon as they are started or stopped, and the members of
+// a cluster follow it.
+type fakeRDS struct {
+	mu        sync.Mutex
+	instances map[string]*types.DBInstance
+	clusters  map[string]*types.DBCluster
+}
+
+func newFakeRDS() *fakeRDS {
+	return &fakeRDS{instances: make(map[string]*types.DBInstance), clusters: make(map[string]*types.DBCluster)}
+}
+
+func (f *fakeRDS) addInstance(id, status string, tags map[string]string) *types.DBInstance {
+	inst := &types.DBInstance{
+		DBInstanceIdentifier: aws.String(id),
+		DBInstanceArn:        aws.String("arn:aws:rds:eu-west-1:123456789012:db:" + id),
+		DBInstanceStatus:     aws.String(status),
+		TagList:              newRDSTags(tags),
+	}
+	f.instances[id] = inst
+	return inst
+}
+
+func (f *fakeRDS) addCluster(id, status string, tags map[string]string, members ...string) *types.DBCluster {
+	cluster := &types.DBCluster{
+		DBClusterIdentifier: aws.String(id),
+		DBClusterArn:        aws.String("arn:aws:rds:eu-west-1:123456789012:cluster:" + id),
+		Status:              aws.String(status),
+		TagList:             newRDSTags(tags),
+	}
+	for _, member := range members {
+		inst := f.addInstance(member, status, nil)
+		inst.DBClusterIdentifier = aws.String(id)
+		cluster.DBClusterMembers = append(cluster.DBClusterMembers, types.DBClusterMember{DBInstanceIdentifier: aws.String(member)})
+	}
+	f.clusters[id] = cluster
+	return cluster
+}
+
+func (f *fakeRDS) DescribeDBInstances(ctx context.Context, params *rds.DescribeDBInstancesInput, optFns ...func(*rds.Options)) (*rds.DescribeDBInstancesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out rds.DescribeDBInstancesOutput
+	for _, inst := range f.instances {
+		if params != nil && !fakeRDSClusterFilterMatches(inst, params.Filters) {
+			continue
+		}
+		i := *inst
+		i.TagList = slices.Clone(inst.TagList)
+		out.DBInstances = append(out.DBInstances, i)
+	}
+	return &out, nil
+}
+
+func (f *fakeRDS) DescribeDBClusters(ctx context.Context, params *rds.DescribeDBClustersInput, optFns ...func(*rds.Options)) (*rds.DescribeDBClustersOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var out rds.DescribeDBClustersOutput
+	for _, cluster := range f.clusters {
+		c := *cluster
+		c.TagList = slices.Clone(cluster.TagList)
+		out.DBClusters = append(out.DBClusters, c)
+	}
+	return &out, nil
+}
+
+func (f *fakeRDS) ListTagsForResource(ctx context.Context, params *rds.ListTagsForResourceInput, optFns ...func(*rds.Options)) (*rds.ListTagsForResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &rds.ListTagsForResourceOutput{TagList: slices.Clone(*f.tags(*params.ResourceName))}, nil
+}
+
+func (f *fakeRDS) StartDBInstance(ctx context.Context, params *rds.StartDBInstanceInput, optFns ...func(*rds.Options)) (*rds.StartDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	instance := f.instances[*params.DBInstanceIdentifier]
+	if aws.ToString(instance.DBInstanceStatus) != rdsStatusStopped {
+		return nil, fmt.Errorf("InvalidDBInstanceState: instance %s is %s", *params.DBInstanceIdentifier, aws.ToString(instance.DBInstanceStatus))
+	}
+	instance.DBInstanceStatus = aws.String(StatusAvailable)
+	return &rds.StartDBInstanceOutput{}, nil
+}
+
+func (f *fakeRDS) StopDBInstance(ctx context.Context, params *rds.StopDBInstanceInput, optFns ...func(*rds.Options)) (*rds.StopDBInstanceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.instances[*params.DBInstanceIdentifier].DBInstanceStatus = aws.String(rdsStatusStopped)
+	return &rds.StopDBInstanceOutput{}, nil
+}
+
+func (f *fakeRDS) StartDBCluster(ctx context.Context, params *rds.StartDBClusterInput, optFns ...func(*rds.Options)) (*rds.StartDBClusterOutput, error) {
+	f.setClusterStatus(*params.DBClusterIdentifier, StatusAvailable)
+	return &rds.StartDBClusterOutput{}, nil
+}
+
+func (f *fakeRDS) StopDBCluster(ctx context.Context, params *rds.StopDBClusterInput, optFns ...func(*rds.Options)) (*rds.StopDBClusterOutput, error) {
+	f.setClusterStatus(*params.DBClusterIdentifier, rdsClusterStatusStopped)
+	return &rds.StopDBClusterOutput{}, nil
+}
+
+func (f *fakeRDS) AddTagsToResource(ctx context.Context, params *rds.AddTagsToResourceInput, optFns ...func(*rds.Options)) (*rds.AddTagsToResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tags := f.tags(*params.ResourceName)
+	for _, tag := range params.Tags {
+		*tags = fakeRDSDeleteTags(*tags, *tag.Key)
+	}
+	*tags = append(*tags, params.Tags...)
+	return &rds.AddTagsToResourceOutput{}, nil
+}
+
+func (f *fakeRDS) RemoveTagsFromResource(ctx context.Context, params *rds.RemoveTagsFromResourceInput, optFns ...func(*rds.Options)) (*rds.RemoveTagsFromResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tags := f.tags(*params.ResourceName)
+	*tags = fakeRDSDeleteTags(*tags, params.TagKeys...)
+	return &rds.RemoveTagsFromResourceOutput{}, nil
+}
+
+func (f *fakeRDS) setClusterStatus(id, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cluster := f.clusters[id]
+	cluster.Status = aws.String(status)
+	for _, member := range cluster.DBClusterMembers {
+		f.instances[*member.DBInstanceIdentifier].DBInstanceStatus = aws.String(status)
+	}
+}
+
+// tags returns the tags of the instance or cluster with the given ARN.
+func (f *fakeRDS) tags(arn string) *[]types.Tag {
+	for _, inst := range f.instances {
+		if *inst.DBInstanceArn == arn {
+			return &inst.TagList
+		}
+	}
+	for _, cluster := range f.clusters {
+		if *cluster.DBClusterArn == arn {
+			return &cluster.TagList
+		}
+	}
+	return &[]types.Tag{}
+}
+
+// fakeRDSClusterFilterMatches understands the db-cluster-id filter used to list the members of a cluster.
+func fakeRDSClusterFilterMatches(inst *types.DBInstance, filters []types.Filter) bool {
+	for _, filter := range filters {
+		if *filter.Name == rdsClusterIDFilter && !slices.Contains(filter.Values, aws.ToString(inst.DBClusterIdentifier)) {
+			return false
+		}
+	}
+	return true
+}
+
+func fakeRDSDeleteTags(tags []types.Tag, keys ...string) []types.Tag {
+	var kept []types.Tag
+	for _, t := range tags {
+		if !slices.Contains(keys, *t.Key) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func fakeRDSTag(tags []types.Tag, key string) (string, bool) {
+	for _, t := range tags {
+		if *t.Key == key {
+			return aws.ToString(t.Value), true
+		}
+	}
+	return "", false
+}
+
+func newRDSTags(tags map[string]string) []types.Tag {
+	var rdsTags []types.Tag
+	for k, v := range tags {
+		rdsTags = append(rdsTags, types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return rdsTags
+}
+
+func TestRDSResourceStartStop(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addInstance("analytics-db", rdsStatusStopped, map[string]string{defaultTagKey: "analytics"})
+	fake.addInstance("reporting-db", rdsStatusStopped, map[string]string{defaultTagKey: "reporting"})
+	resource := &RDSResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 1, Running: 1, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+	if got := *fake.instances["reporting-db"].DBInstanceStatus; got != rdsStatusStopped {
+		t.Errorf("instance of another resource has status %s, want %s", got, rdsStatusStopped)
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); err == nil {
+		t.Error("Stop() by another user should fail while the resource is locked")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 1}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+}
+
+func TestRDSResourceRelock(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addInstance("analytics-db", rdsStatusStopped, map[string]string{defaultTagKey: "analytics"})
+	resource := &RDSResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	extendedEndAt := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// Extending the booking starts the running resource again with a later end
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: extendedEndAt}); err != nil {
+		t.Fatalf("Start() of the running resource error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want := ResourceStatusOutput{Available: 1, Running: 1, LockedBy: "alice", LockedUntil: extendedEndAt}
+	if rst != want {
+		t.Errorf("Status() = %+v, want %+v", rst, want)
+	}
+}
+
+func TestRDSResourceSkipsClusterMembers(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addInstance("analytics-db", rdsStatusStopped, map[string]string{defaultTagKey: "analytics"})
+	fake.addCluster("analytics-aurora", rdsClusterStatusStopped, nil, "analytics-aurora-1")
+	// Members can carry the tags of the cluster, but only their cluster can start and stop them
+	fake.instances["analytics-aurora-1"].TagList = newRDSTags(map[string]string{defaultTagKey: "analytics"})
+	resource := &RDSResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if rst.Available != 1 || rst.Running != 1 {
+		t.Errorf("Status() = %+v, want only the standalone instance", rst)
+	}
+	member := fake.instances["analytics-aurora-1"]
+	if got := *member.DBInstanceStatus; got != rdsClusterStatusStopped {
+		t.Errorf("cluster member has status %s, want %s", got, rdsClusterStatusStopped)
+	}
+	if _, ok := fakeRDSTag(member.TagList, lockedByTag); ok {
+		t.Error("cluster member was locked")
+	}
+}
+
+func TestRDSAutoStarted(t *testing.T) {
+	now := time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC)
+	stoppedAt := func(ago time.Duration) string { return now.Add(-ago).Format(time.RFC3339) }
+
+	tests := []struct {
+		name string
+		tags map[string]string
+		want bool
+	}{
+		{"stopped for seven days", map[string]string{stoppedAtTag: stoppedAt(rdsAutoStartAfter)}, true},
+		{"stopped for longer", map[string]string{stoppedAtTag: stoppedAt(rdsAutoStartAfter + time.Hour)}, true},
+		{"stopped for less than seven days", map[string]string{stoppedAtTag: stoppedAt(rdsAutoStartAfter - time.Minute)}, false},
+		{"locked by a booking", map[string]string{stoppedAtTag: stoppedAt(rdsAutoStartAfter), lockedByTag: "alice"}, false},
+		{"never stopped by the operator", nil, false},
+		{"invalid stop time", map[string]string{stoppedAtTag: "last week"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := types.DBInstance{TagList: newRDSTags(tt.tags)}
+			if got := rdsAutoStarted(instance, now); got != tt.want {
+				t.Errorf("rdsAutoStarted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRDSResourceStopAutoStarted(t *testing.T) {
+	weekAgo := time.Now().Add(-rdsAutoStartAfter - time.Hour).UTC().Format(time.RFC3339)
+	fake := newFakeRDS()
+	fake.addInstance("analytics-auto", StatusAvailable, map[string]string{defaultTagKey: "analytics", stoppedAtTag: weekAgo})
+	fake.addInstance("analytics-booked", StatusAvailable, map[string]string{defaultTagKey: "analytics", stoppedAtTag: weekAgo, lockedByTag: "alice"})
+	fake.addInstance("analytics-starting", "starting", map[string]string{defaultTagKey: "analytics", stoppedAtTag: weekAgo})
+	fake.addInstance("reporting-auto", StatusAvailable, map[string]string{defaultTagKey: "reporting", stoppedAtTag: weekAgo})
+	resource := &RDSResource{NameTag: "analytics", Client: fake}
+
+	stopped, err := resource.StopAutoStarted()
+	if err != nil {
+		t.Fatalf("StopAutoStarted() error = %v", err)
+	}
+	if len(stopped) != 1 || stopped[0] != "analytics-auto" {
+		t.Errorf("StopAutoStarted() = %v, want [analytics-auto]", stopped)
+	}
+
+	for id, want := range map[string]string{
+		"analytics-auto":     rdsStatusStopped,
+		"analytics-booked":   StatusAvailable,
+		"analytics-starting": "starting",
+		"reporting-auto":     StatusAvailable,
+	} {
+		if got := *fake.instances[id].DBInstanceStatus; got != want {
+			t.Errorf("status of %s = %s, want %s", id, got, want)
+		}
+	}
+
+	// The stop time starts over, so the instance counts as auto-started again only after another seven days
+	if got, _ := fakeRDSTag(fake.instances["analytics-auto"].TagList, stoppedAtTag); got == weekAgo {
+		t.Errorf("%s of the stopped instance = %s, want the time it was stopped again", stoppedAtTag, got)
+	}
+	fake.instances["analytics-auto"].DBInstanceStatus = aws.String(StatusAvailable)
+	if stopped, err := resource.StopAutoStarted(); err != nil || len(stopped) != 0 {
+		t.Errorf("StopAutoStarted() right after a stop = %v, %v, want no instances", stopped, err)
+	}
+}
@@ -0,0 +1,230 @@
+package clients
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+const (
+	rdsClusterStatusStopped string = "stopped"
+	rdsClusterIDFilter      string = "db-cluster-id"
+)
+
+// RDSClusterResource represents a collection of RDS DB clusters, like Aurora clusters, grouped by a common "resource-booking-application" tag.
+// The members of a cluster can't be started or stopped on their own, so the whole cluster is started and stopped instead.
+type RDSClusterResource struct {
+	NameTag string
+	Client  RDSAPI
+}
+
+type RDSClusterMonitor struct {
+	Type   string
+	Client RDSAPI
+}
+
+type rdsClusterDetails struct {
+	Clusters []types.DBCluster
+	Tags     map[string]string
+}
+
+// Start starts the stopped clusters of the resource and locks them with tags.
+func (r *RDSClusterResource) Start(startInput ResourceStartInput) error {
+	clusters, err := r.getClusterDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(startInput.UID, clusters.Tags, startInput.Preempts); err != nil {
+		return err
+	}
+
+	for _, cluster := range clusters.Clusters {
+		// A cluster can only be started from the stopped state
+		if aws.ToString(cluster.Status) == rdsClusterStatusStopped {
+			_, err = r.Client.StartDBCluster(rdsCtx, &rds.StartDBClusterInput{
+				DBClusterIdentifier: cluster.DBClusterIdentifier,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = r.Client.AddTagsToResource(rdsCtx, &rds.AddTagsToResourceInput{
+			ResourceName: cluster.DBClusterArn,
+			Tags: []types.Tag{
+				{Key: &lockedByTag, Value: &startInput.UID},
+				{Key: &lockedUntilTag, Value: &startInput.EndAt},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stop stops the available clusters of the resource and removes their lock tags.
+func (r *RDSClusterResource) Stop(stopInput ResourceStopInput) error {
+	clusters, err := r.getClusterDetails(r.NameTag)
+	if err != nil {
+		return err
+	}
+
+	if err = checkLock(stopInput.UID, clusters.Tags, ""); err != nil {
+		return err
+	}
+
+	for _, cluster := range clusters.Clusters {
+		// A cluster can only be stopped from the available state
+		if aws.ToString(cluster.Status) == StatusAvailable {
+			_, err = r.Client.StopDBCluster(rdsCtx, &rds.StopDBClusterInput{
+				DBClusterIdentifier: cluster.DBClusterIdentifier,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = r.Client.RemoveTagsFromResource(rdsCtx, &rds.RemoveTagsFromResourceInput{
+			ResourceName: cluster.DBClusterArn,
+			TagKeys:      []string{lockedByTag, lockedUntilTag},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Status returns the members of the resource clusters as instances, and the available ones as running.
+// A cluster without members, like an Aurora Serverless v1 cluster, counts as a single instance.
+func (r *RDSClusterResource) Status() (ResourceStatusOutput, error) {
+	var rst ResourceStatusOutput
+
+	clusters, err := r.getClusterDetails(r.NameTag)
+	if err != nil {
+		return rst, err
+	}
+
+	for _, cluster := range clusters.Clusters {
+		if len(cluster.DBClusterMembers) == 0 {
+			rst.Available++
+			if aws.ToString(cluster.Status) == StatusAvailable {
+				rst.Running++
+			}
+			continue
+		}
+
+		members, err := listRDSClusterMembers(r.Client, aws.ToString(cluster.DBClusterIdentifier))
+		if err != nil {
+			return rst, err
+		}
+
+		rst.Available += len(cluster.DBClusterMembers)
+		for _, member := range members {
+			if aws.ToString(member.DBInstanceStatus) == StatusAvailable {
+				rst.Running++
+			}
+		}
+	}
+
+	rst.LockedBy, rst.LockedUntil = clusters.Tags[lockedByTag], clusters.Tags[lockedUntilTag]
+
+	return rst, nil
+}
+
+// getClusterDetails returns the clusters tagged with the given name tag, along with their lock tags.
+func (r *RDSClusterResource) getClusterDetails(nameTag string) (rdsClusterDetails, error) {
+	details := rdsClusterDetails{Tags: make(map[string]string)}
+
+	clusters, err := listRDSClusters(r.Client, defaultTagKey, nameTag)
+	if err != nil {
+		return details, err
+	}
+	details.Clusters = clusters
+
+	// The clusters of a resource are locked together, so the tags of any of them are the lock of the resource
+	for _, cluster := range clusters {
+		for _, t := range cluster.TagList {
+			if *t.Key == lockedByTag || *t.Key == lockedUntilTag {
+				details.Tags[*t.Key] = aws.ToString(t.Value)
+			}
+		}
+
+		if len(details.Tags) == 2 {
+			break
+		}
+	}
+
+	return details, nil
+}
+
+// GetNewResources compares the local cluster resources with the DB clusters returned from RDS
+// and gives back a list of resources that need to be created on the cluster.
+func (m *RDSClusterMonitor) GetNewResources(clusterResources map[string]bool) ([]string, error) {
+	uniqueTags := make(map[string]bool)
+
+	clusters, err := listRDSClusters(m.Client, resourceMonitorTagKey, "true")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cluster := range clusters {
+		for _, t := range cluster.TagList {
+			if *t.Key == defaultTagKey {
+				uniqueTags[aws.ToString(t.Value)] = true
+			}
+		}
+	}
+
+	slice1, slice2 := setDiff(uniqueTags, clusterResources), setDiff(clusterResources, uniqueTags)
+	nonMatchingTags := append(slice1, slice2...)
+
+	return nonMatchingTags, nil
+}
+
+// listRDSClusters returns the DB clusters that have the given tag set to the given value.
+// RDS can't filter clusters by tags, so the filtering is done here.
+func listRDSClusters(rdsClient RDSAPI, tagKey, tagValue string) ([]types.DBCluster, error) {
+	var clusters []types.DBCluster
+
+	pages := rds.NewDescribeDBClustersPaginator(rdsClient, &rds.DescribeDBClustersInput{})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(rdsCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range page.DBClusters {
+			for _, t := range cluster.TagList {
+				if *t.Key == tagKey && aws.ToString(t.Value) == tagValue {
+					clusters = append(clusters, cluster)
+					break
+				}
+			}
+		}
+	}
+
+	return clusters, nil
+}
+
+// listRDSClusterMembers returns the DB instances that are members of the given cluster.
+func listRDSClusterMembers(rdsClient RDSAPI, clusterID string) ([]types.DBInstance, error) {
+	var instances []types.DBInstance
+
+	pages := rds.NewDescribeDBInstancesPaginator(rdsClient, &rds.DescribeDBInstancesInput{
+		Filters: []types.Filter{{Name: aws.String(rdsClusterIDFilter), Values: []string{clusterID}}},
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(rdsCtx)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, page.DBInstances...)
+	}
+
+	return instances, nil
+}
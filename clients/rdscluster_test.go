@@ -0,0 +1,80 @@
+package clients
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRDSClusterResourceStartStop(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("analytics-aurora", rdsClusterStatusStopped, map[string]string{defaultTagKey: "analytics"}, "analytics-aurora-1", "analytics-aurora-2")
+	fake.addCluster("analytics-serverless", rdsClusterStatusStopped, map[string]string{defaultTagKey: "analytics"})
+	fake.addCluster("reporting-aurora", rdsClusterStatusStopped, map[string]string{defaultTagKey: "reporting"}, "reporting-aurora-1")
+	resource := &RDSClusterResource{NameTag: "analytics", Client: fake}
+	endAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	rst, err := resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	// A cluster without members counts as a single instance
+	want := ResourceStatusOutput{Available: 3}
+	if rst != want {
+		t.Errorf("Status() of the stopped resource = %+v, want %+v", rst, want)
+	}
+
+	if err := resource.Start(ResourceStartInput{UID: "alice", EndAt: endAt}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 3, Running: 3, LockedBy: "alice", LockedUntil: endAt}
+	if rst != want {
+		t.Errorf("Status() of the started resource = %+v, want %+v", rst, want)
+	}
+	if got := *fake.clusters["reporting-aurora"].Status; got != rdsClusterStatusStopped {
+		t.Errorf("cluster of another resource has status %s, want %s", got, rdsClusterStatusStopped)
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "bob"}); err == nil {
+		t.Error("Stop() by another user should fail while the resource is locked")
+	}
+	if err := resource.Start(ResourceStartInput{UID: "bob", EndAt: endAt, Preempts: "carol"}); err == nil {
+		t.Error("Start() preempting another user than the lock holder should fail")
+	}
+
+	if err := resource.Stop(ResourceStopInput{UID: "alice"}); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	rst, err = resource.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	want = ResourceStatusOutput{Available: 3}
+	if rst != want {
+		t.Errorf("Status() after Stop() = %+v, want %+v", rst, want)
+	}
+	if _, ok := fakeRDSTag(fake.clusters["analytics-aurora"].TagList, lockedByTag); ok {
+		t.Errorf("%s tag is left after Stop()", lockedByTag)
+	}
+}
+
+func TestRDSClusterMonitorGetNewResources(t *testing.T) {
+	fake := newFakeRDS()
+	fake.addCluster("analytics-aurora", StatusAvailable, map[string]string{defaultTagKey: "analytics", resourceMonitorTagKey: "true"})
+	fake.addCluster("reporting-aurora", StatusAvailable, map[string]string{defaultTagKey: "reporting", resourceMonitorTagKey: "true"})
+	fake.addCluster("unmanaged-aurora", StatusAvailable, map[string]string{defaultTagKey: "unmanaged"})
+	monitor := &RDSClusterMonitor{Type: TypeRDSCluster, Client: fake}
+
+	tags, err := monitor.GetNewResources(map[string]bool{"analytics": true})
+	if err != nil {
+		t.Fatalf("GetNewResources() error = %v", err)
+	}
+	if len(tags) != 1 || tags[0] != "reporting" {
+		t.Errorf("GetNewResources() = %v, want [reporting]", tags)
+	}
+}
@@ -18,27 +18,63 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	"github.com/kotaicode/resource-booking-operator/clients"
 	"github.com/kotaicode/resource-booking-operator/notify"
 )
 
+const (
+	// notificationRetryInterval is how long to wait before retrying failed deliveries.
+	notificationRetryInterval = time.Minute
+	// maxNotificationAttempts is how many times the delivery of an event is attempted before it is given up.
+	maxNotificationAttempts = 5
+	// bookingFinalizer keeps bookings that didn't finish around until they are cancelled.
+	bookingFinalizer = "manager.kotaico.de/booking-cleanup"
+)
+
+// bookingStatusReasons are the reasons of the events recorded when a booking changes to each status.
+var bookingStatusReasons = map[string]string{
+	managerv1.BookingScheduled:  "Scheduled",
+	managerv1.BookingInProgress: "Started",
+	managerv1.BookingFinished:   "Finished",
+	managerv1.BookingQueued:     "Queued",
+}
+
 // BookingReconciler reconciles a Booking object
 type BookingReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// NotificationTemplates names the ConfigMap with the templates of the notification content, if any.
+	NotificationTemplates types.NamespacedName
+	// SMTP is the config of email notifications, unless SMTPSecret names a Secret with the SMTP settings.
+	SMTP       notify.EmailConfig
+	SMTPSecret types.NamespacedName
+	// Clients are the cloud and cluster clients that the resources of cancelled bookings are stopped with.
+	Clients  clients.Clients
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookings,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookings/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookings/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -56,14 +92,20 @@ func (r *BookingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	bookStart, err := time.Parse(time.RFC3339, booking.Spec.StartAt)
-	if err != nil {
-		log.Error(err, "Error parsing booking start")
+	if !booking.DeletionTimestamp.IsZero() {
+		if err := r.cancelBooking(ctx, &booking); err != nil {
+			log.Error(err, "Error cancelling booking")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
 	}
 
-	bookEnd, err := time.Parse(time.RFC3339, booking.Spec.EndAt)
-	if err != nil {
-		log.Error(err, "Error parsing booking end")
+	if booking.Status.Status != managerv1.BookingFinished && booking.Status.Status != managerv1.BookingPreempted &&
+		controllerutil.AddFinalizer(&booking, bookingFinalizer) {
+		if err := r.Update(ctx, &booking); err != nil {
+			log.Error(err, "Error adding booking finalizer")
+			return ctrl.Result{}, err
+		}
 	}
 
 	resNamespacedName := types.NamespacedName{
@@ -74,36 +116,58 @@ func (r *BookingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	var resource managerv1.Resource
 	if err := r.Get(context.Background(), resNamespacedName, &resource); err != nil {
 		log.Error(err, "Error listing bookings")
+		if apierrors.IsNotFound(err) {
+			r.Recorder.Eventf(&booking, corev1.EventTypeWarning, "ResourceNotFound", "Resource %s doesn't exist", booking.Spec.ResourceName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if bookStart.Before(time.Now()) && time.Now().Before(bookEnd) {
+	if err := r.applyEndRequest(ctx, &booking, &resource); err != nil {
+		log.Error(err, "Error applying booking extension or release")
+		return ctrl.Result{}, err
+	}
+
+	waiting, promoted, err := r.advanceQueue(ctx, &booking)
+	if err != nil {
+		log.Error(err, "Error promoting queued booking")
+		return ctrl.Result{}, err
+	}
+
+	bookStart, bookEnd, err := booking.Window()
+	if err != nil {
+		log.Error(err, "Error parsing booking window")
+	}
+
+	previousStatus := booking.Status.Status
+	switch {
+	case booking.Status.Status == managerv1.BookingPreempted:
+		// Preempted bookings don't get their resource back
+	case waiting:
+		booking.Status.Status = managerv1.BookingQueued
+	case bookStart.Before(time.Now()) && time.Now().Before(bookEnd):
 		booking.Status.Status = managerv1.BookingInProgress
+		if err := r.preemptBookings(ctx, &booking, &resource); err != nil {
+			log.Error(err, "Error preempting bookings")
+			return ctrl.Result{}, err
+		}
 		updateResource(r, ctx, &resource, &booking)
-	} else if bookEnd.Before(time.Now()) {
+	case bookEnd.Before(time.Now()):
 		booking.Status.Status = managerv1.BookingFinished
 		updateResource(r, ctx, &resource, &booking)
-	} else {
+	default:
 		booking.Status.Status = managerv1.BookingScheduled
 	}
 
-	if booking.Status.Status == managerv1.BookingInProgress && time.Until(bookEnd) < time.Minute*20 &&
-		!booking.Status.NotificationSent && len(booking.Spec.Notifications) > 0 {
-
-		for _, notification := range booking.Spec.Notifications {
-			n, err := notify.NewNotifier(notification)
-			if err != nil {
-				log.Error(err, "Error sending notification")
-			}
-
-			err = n.Prepare(booking).Send()
-			if err != nil {
-				log.Error(err, "Error sending notification")
-			}
+	if booking.Status.Status != previousStatus {
+		r.Recorder.Eventf(&booking, corev1.EventTypeNormal, bookingStatusReasons[booking.Status.Status],
+			"Booking of %s by %s is %s", booking.Spec.ResourceName, booking.Spec.UserID, booking.Status.Status)
+	}
 
-			booking.Status.NotificationSent = err == nil
-		}
+	events := notificationEvents(&booking, &resource)
+	if promoted {
+		events = append(events, managerv1.NotificationEventPromoted)
 	}
+	pending := r.sendNotifications(ctx, &booking, &resource, events, bookEnd)
 
 	log.Info("Updating booking status", "status", booking.Status.Status)
 	err = r.Status().Update(ctx, &booking)
@@ -112,34 +176,684 @@ func (r *BookingReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
-	if booking.Status.Status == managerv1.BookingFinished {
+	// Finished and preempted bookings have nothing left to clean up
+	if (booking.Status.Status == managerv1.BookingFinished || booking.Status.Status == managerv1.BookingPreempted) &&
+		controllerutil.RemoveFinalizer(&booking, bookingFinalizer) {
+		if err := r.Update(ctx, &booking); err != nil {
+			log.Error(err, "Error removing booking finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	requeueAfter := nextReconcile(&booking, bookStart, bookEnd, pending)
+	if requeueAfter == 0 {
 		log.Info("Booking finished")
 		return ctrl.Result{}, nil
 	}
 
-	return ctrl.Result{RequeueAfter: time.Duration(time.Minute * 1)}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// applyEndRequest applies the extension or release requested through spec.extend_by or spec.release to the end of the
+// booking, records it in the history of the booking, and clears the request. Extensions that would overlap a booking
+// of another user are rejected, and requests for bookings that finished or haven't started are dropped. A resource
+// booked until the previous end of the booking is booked until the new one right away.
+// The history is written before the request is cleared. When clearing it failed, the recorded entry of the request is
+// applied on the next reconcile instead of deciding the request again.
+func (r *BookingReconciler) applyEndRequest(ctx context.Context, booking *managerv1.Booking, resource *managerv1.Resource) error {
+	if booking.Spec.ExtendBy == nil && !booking.Spec.Release {
+		return nil
+	}
+
+	previousEnd := booking.Spec.EndAt
+	entry := recordedEndRequest(booking)
+	if entry == nil {
+		var err error
+		if entry, err = r.decideEndRequest(ctx, booking); err != nil {
+			return err
+		}
+
+		if entry != nil {
+			entry.Generation = booking.Generation
+			booking.Status.History = append(booking.Status.History, *entry)
+			if err := r.Status().Update(ctx, booking); err != nil {
+				return err
+			}
+		}
+	}
+
+	if entry != nil {
+		booking.Spec.EndAt = entry.EndAt
+	}
+	booking.Spec.ExtendBy, booking.Spec.Release = nil, false
+	if err := r.Update(ctx, booking); err != nil {
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	switch {
+	case booking.Spec.EndAt == previousEnd || resource.Spec.BookedBy != booking.Spec.UserID || resource.Spec.BookedUntil != previousEnd:
+		return nil
+	case entry.Action == managerv1.BookingActionReleased:
+		return r.releaseResource(ctx, booking, resource)
+	default:
+		resource.Spec.BookedUntil = booking.Spec.EndAt
+		return r.Update(ctx, resource)
+	}
+}
+
+// decideEndRequest decides the extension or release requested through the spec of the booking, and returns the history
+// entry that records it. Requests that are dropped aren't recorded.
+func (r *BookingReconciler) decideEndRequest(ctx context.Context, booking *managerv1.Booking) (*managerv1.BookingHistoryEntry, error) {
+	now := time.Now().UTC()
+	previousEnd := booking.Spec.EndAt
+	entry := &managerv1.BookingHistoryEntry{At: now.Format(time.RFC3339), PreviousEndAt: previousEnd, EndAt: previousEnd}
+
+	start, end, err := booking.Window()
+	switch {
+	case err != nil || now.After(end):
+		r.Recorder.Event(booking, corev1.EventTypeWarning, "RequestDropped", "Finished bookings can't be extended or released")
+		return nil, nil
+	case booking.Spec.Release && now.Before(start):
+		r.Recorder.Event(booking, corev1.EventTypeWarning, "RequestDropped", "Only bookings that started can be released")
+		return nil, nil
+	case booking.Spec.Release:
+		entry.Action, entry.EndAt = managerv1.BookingActionReleased, entry.At
+		r.Recorder.Eventf(booking, corev1.EventTypeNormal, "Released", "Released the booking early, it was booked until %s", previousEnd)
+		return entry, nil
+	}
+
+	conflict, err := r.conflictingBooking(ctx, booking)
+	if err != nil {
+		return nil, err
+	}
+
+	if conflict != nil {
+		entry.Action = managerv1.BookingActionExtensionRejected
+		entry.Message = fmt.Sprintf("resource %s is booked by %s from %s to %s (booking %s)", conflict.Spec.ResourceName,
+			conflict.Spec.UserID, conflict.Spec.StartAt, conflict.Spec.EndAt, conflict.Name)
+		r.Recorder.Eventf(booking, corev1.EventTypeWarning, "ExtensionRejected", "Extending the booking by %s was rejected: %s",
+			booking.Spec.ExtendBy.Duration, entry.Message)
+	} else {
+		entry.Action, entry.EndAt = managerv1.BookingActionExtended, end.Add(booking.Spec.ExtendBy.Duration).UTC().Format(time.RFC3339)
+		r.Recorder.Eventf(booking, corev1.EventTypeNormal, "Extended", "Extended the booking by %s until %s",
+			booking.Spec.ExtendBy.Duration, entry.EndAt)
+	}
+
+	return entry, nil
+}
+
+// recordedEndRequest returns the history entry of the request in the spec of the booking, if it was already recorded.
+// Clearing a request changes the generation of the booking, so an entry of the current generation is of that request.
+func recordedEndRequest(booking *managerv1.Booking) *managerv1.BookingHistoryEntry {
+	history := booking.Status.History
+	if booking.Generation == 0 || len(history) == 0 || history[len(history)-1].Generation != booking.Generation {
+		return nil
+	}
+
+	entry := history[len(history)-1]
+	return &entry
+}
+
+// advanceQueue keeps a queued booking waiting while a booking ahead of it blocks it, and otherwise promotes it to a
+// regular booking: it clears spec.queue, and moves the start of a booking that waited past its start to the time of
+// the promotion. It reports whether the booking is waiting, and whether it was promoted after waiting. Queued bookings
+// that ended while waiting are left to finish without ever booking the resource.
+func (r *BookingReconciler) advanceQueue(ctx context.Context, booking *managerv1.Booking) (bool, bool, error) {
+	if !booking.Spec.Queue {
+		return false, false, nil
+	}
+
+	now := time.Now().UTC()
+	start, end, err := booking.Window()
+	if err != nil || !now.Before(end) {
+		return false, false, nil
+	}
+
+	blocker, err := r.queueBlocker(ctx, booking, now)
+	if err != nil || blocker != nil {
+		return err == nil, false, err
+	}
+
+	wasWaiting := booking.Status.Status == managerv1.BookingQueued
+	booking.Spec.Queue = false
+	if wasWaiting && start.Before(now) {
+		booking.Spec.StartAt = now.Format(time.RFC3339)
+	}
+	if err := r.Update(ctx, booking); err != nil {
+		return false, false, err
+	}
+	if !wasWaiting {
+		return false, false, nil
+	}
+
+	booking.Status.History = append(booking.Status.History, managerv1.BookingHistoryEntry{
+		Action:        managerv1.BookingActionPromoted,
+		At:            now.Format(time.RFC3339),
+		PreviousEndAt: booking.Spec.EndAt,
+		EndAt:         booking.Spec.EndAt,
+	})
+	r.Recorder.Eventf(booking, corev1.EventTypeNormal, "Promoted", "Promoted the queued booking, it lasts from %s until %s",
+		booking.Spec.StartAt, booking.Spec.EndAt)
+
+	return false, true, nil
 }
 
+// queueBlocker returns the booking that a queued booking waits for, if any. That is a booking of the same resource by
+// another user, whose window intersects what is left of the window of the queued booking, and that is either active,
+// or queued ahead of it.
+func (r *BookingReconciler) queueBlocker(ctx context.Context, booking *managerv1.Booking, now time.Time) (*managerv1.Booking, error) {
+	var bookings managerv1.BookingList
+	if err := r.List(ctx, &bookings, client.InNamespace(booking.Namespace), client.MatchingFields{"spec.resource_name": booking.Spec.ResourceName}); err != nil {
+		return nil, err
+	}
+
+	start, end, err := booking.Window()
+	if err != nil {
+		return nil, err
+	}
+	if start.Before(now) {
+		start = now
+	}
+
+	for i := range bookings.Items {
+		other := &bookings.Items[i]
+		if other.Name == booking.Name || other.Spec.UserID == booking.Spec.UserID || other.Status.Status == managerv1.BookingFinished ||
+			other.Status.Status == managerv1.BookingPreempted || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if other.Spec.Queue && !queuedAhead(other, booking) {
+			continue
+		}
+
+		otherStart, otherEnd, err := other.Window()
+		if err != nil {
+			continue
+		}
+		if start.Before(otherEnd) && otherStart.Before(end) && now.Before(otherEnd) {
+			return other, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// preemptBookings preempts the bookings of the resource by other users that have a lower priority than the booking in
+// progress, and overlap what is left of it. They are marked as preempted, and notify their users about it when they are
+// reconciled next. When one of them holds the resource, the resource records its user as preempted, so that the lock
+// of its instances is handed over once the resource is booked for the booking.
+func (r *BookingReconciler) preemptBookings(ctx context.Context, booking *managerv1.Booking, resource *managerv1.Resource) error {
+	var bookings managerv1.BookingList
+	if err := r.List(ctx, &bookings, client.InNamespace(booking.Namespace), client.MatchingFields{"spec.resource_name": booking.Spec.ResourceName}); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	_, end, err := booking.Window()
+	if err != nil {
+		return err
+	}
+
+	for i := range bookings.Items {
+		other := &bookings.Items[i]
+		if other.Name == booking.Name || other.Spec.UserID == booking.Spec.UserID || other.Spec.Priority >= booking.Spec.Priority ||
+			other.Spec.Queue || other.Status.Status == managerv1.BookingFinished || other.Status.Status == managerv1.BookingPreempted ||
+			!other.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		otherStart, otherEnd, err := other.Window()
+		if err != nil || !otherStart.Before(end) || !now.Before(otherEnd) {
+			continue
+		}
+
+		other.Status.Status = managerv1.BookingPreempted
+		other.Status.History = append(other.Status.History, managerv1.BookingHistoryEntry{
+			Action:        managerv1.BookingActionPreempted,
+			At:            now.Format(time.RFC3339),
+			PreviousEndAt: other.Spec.EndAt,
+			EndAt:         other.Spec.EndAt,
+			Message: fmt.Sprintf("preempted by booking %s of %s with priority %d", booking.Name, booking.Spec.UserID,
+				booking.Spec.Priority),
+		})
+		if err := r.Status().Update(ctx, other); err != nil {
+			return err
+		}
+
+		r.Recorder.Eventf(other, corev1.EventTypeWarning, "Preempted", "Booking of %s by %s was preempted by booking %s with priority %d",
+			other.Spec.ResourceName, other.Spec.UserID, booking.Name, booking.Spec.Priority)
+		r.Recorder.Eventf(booking, corev1.EventTypeNormal, "BookingPreempted", "Preempted booking %s of %s with priority %d",
+			other.Name, other.Spec.UserID, other.Spec.Priority)
+
+		if resource.Spec.BookedBy == other.Spec.UserID {
+			resource.Spec.PreemptedBy = other.Spec.UserID
+		}
+	}
+
+	return nil
+}
+
+// queuedAhead reports whether the queued booking a was queued before b, in the order of creation.
+func queuedAhead(a, b *managerv1.Booking) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+// cancelBooking cleans up after a booking that is deleted before it finished: it hands back the resource when the booking
+// holds it, and notifies about the cancellation of bookings that didn't end yet. The finalizer is removed afterwards,
+// failed notifications don't hold up the deletion.
+func (r *BookingReconciler) cancelBooking(ctx context.Context, booking *managerv1.Booking) error {
+	if !controllerutil.ContainsFinalizer(booking, bookingFinalizer) {
+		return nil
+	}
+
+	var resource managerv1.Resource
+	err := r.Get(ctx, types.NamespacedName{Namespace: booking.Namespace, Name: booking.Spec.ResourceName}, &resource)
+	if client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	if err == nil && resource.Spec.BookedBy == booking.Spec.UserID && resource.Spec.BookedUntil == booking.Spec.EndAt {
+		if err := r.releaseResource(ctx, booking, &resource); err != nil {
+			return err
+		}
+	}
+
+	if _, bookEnd, err := booking.Window(); err == nil && time.Now().Before(bookEnd) && booking.Status.Status != managerv1.BookingPreempted {
+		r.sendNotifications(ctx, booking, &resource, []string{managerv1.NotificationEventCancelled}, bookEnd)
+		if err := r.Status().Update(ctx, booking); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(booking, corev1.EventTypeNormal, "Cancelled", "Booking of %s by %s was cancelled", booking.Spec.ResourceName, booking.Spec.UserID)
+	}
+
+	controllerutil.RemoveFinalizer(booking, bookingFinalizer)
+	return r.Update(ctx, booking)
+}
+
+// releaseResource hands back the resource booked by the booking before the booking ends. It stops the instances as the
+// user of the booking, which also removes their lock, and clears the booking of the resource. Instances that fail to
+// stop are left to the resource controller, which stops them once their lock expires.
+func (r *BookingReconciler) releaseResource(ctx context.Context, booking *managerv1.Booking, resource *managerv1.Resource) error {
+	log := log.FromContext(ctx)
+
+	cloudResource, err := clients.ResourceFactory(resource.Spec.Type, resource.Spec.Tag, r.Clients)
+	if err == nil {
+		err = cloudResource.Stop(clients.ResourceStopInput{UID: booking.Spec.UserID})
+	}
+	if err != nil {
+		log.Error(err, "Error stopping resource instances")
+		r.Recorder.Eventf(booking, corev1.EventTypeWarning, "StopFailed", "Stopping the instances of resource %s failed: %v", resource.Name, err)
+	}
+
+	resource.Spec.BookedBy, resource.Spec.BookedUntil, resource.Spec.PreemptedBy = "", "", ""
+	if err := r.Update(ctx, resource); err != nil {
+		return err
+	}
+
+	r.Recorder.Eventf(booking, corev1.EventTypeNormal, "ResourceReleased", "Released resource %s", resource.Name)
+	return nil
+}
+
+// conflictingBooking returns a booking that conflicts with the booking once its requested extension is applied, if any.
+func (r *BookingReconciler) conflictingBooking(ctx context.Context, booking *managerv1.Booking) (*managerv1.Booking, error) {
+	var bookings managerv1.BookingList
+	if err := r.List(ctx, &bookings, client.InNamespace(booking.Namespace), client.MatchingFields{"spec.resource_name": booking.Spec.ResourceName}); err != nil {
+		return nil, err
+	}
+
+	for i := range bookings.Items {
+		if booking.ConflictsWith(&bookings.Items[i]) {
+			return &bookings.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// nextReconcile returns how long until the booking has to be reconciled again: when it starts, ends or is due a reminder.
+// Failed deliveries are retried after notificationRetryInterval. Changes of the booked resource, like its instances
+// starting, trigger reconciles on their own. It returns zero once the booking is finished and nothing is left to deliver.
+func nextReconcile(booking *managerv1.Booking, bookStart, bookEnd time.Time, pending bool) time.Duration {
+	now := time.Now()
+
+	var next time.Time
+	consider := func(t time.Time) {
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	consider(bookStart)
+	consider(bookEnd)
+	for _, notification := range booking.Spec.Notifications {
+		if notification.Wants(managerv1.NotificationEventExpiring) {
+			for _, leadTime := range notification.ReminderLeadTimes() {
+				consider(bookEnd.Add(-leadTime))
+			}
+		}
+	}
+	if pending {
+		consider(now.Add(notificationRetryInterval))
+	}
+
+	if next.IsZero() {
+		return 0
+	}
+	return next.Sub(now)
+}
+
+// notificationEvents returns the events the booking is due to notify about, given its status and the state of its resource.
+func notificationEvents(booking *managerv1.Booking, resource *managerv1.Resource) []string {
+	events := bookingEvents(booking, resource)
+	if booking.Status.Status != managerv1.BookingPreempted && lastEndChange(booking) != nil {
+		events = append(events, managerv1.NotificationEventUpdated)
+	}
+	return events
+}
+
+// bookingEvents returns the events the booking is due to notify about in its status.
+func bookingEvents(booking *managerv1.Booking, resource *managerv1.Resource) []string {
+	switch booking.Status.Status {
+	case managerv1.BookingScheduled:
+		return []string{managerv1.NotificationEventScheduled}
+	case managerv1.BookingInProgress:
+		var events []string
+		if resource.Status.Status == clients.StatusRunning && resource.Status.LockedBy == booking.Spec.UserID {
+			events = append(events, managerv1.NotificationEventStarted)
+		}
+		if resource.Status.StartError != "" {
+			events = append(events, managerv1.NotificationEventStartFailed)
+		}
+		// Whether a reminder is due depends on the reminders of each notification
+		return append(events, managerv1.NotificationEventExpiring)
+	case managerv1.BookingFinished:
+		return []string{managerv1.NotificationEventEnded}
+	case managerv1.BookingPreempted:
+		return []string{managerv1.NotificationEventPreempted}
+	}
+
+	return nil
+}
+
+// lastEndChange returns the history entry of the latest extension or release of the booking, if any.
+func lastEndChange(booking *managerv1.Booking) *managerv1.BookingHistoryEntry {
+	for i := len(booking.Status.History) - 1; i >= 0; i-- {
+		entry := &booking.Status.History[i]
+		if entry.Action == managerv1.BookingActionExtended || entry.Action == managerv1.BookingActionReleased {
+			return entry
+		}
+	}
+	return nil
+}
+
+// sendNotifications sends the events through the notifications of the booking that want them, and records the deliveries
+// in the booking status. Each event, or reminder of the expiring event, is delivered once per notification, and failed
+// deliveries are retried on the following reconciles, up to maxNotificationAttempts. It reports whether any failed delivery
+// is left to retry.
+func (r *BookingReconciler) sendNotifications(ctx context.Context, booking *managerv1.Booking, resource *managerv1.Resource, events []string, bookEnd time.Time) bool {
+	log := log.FromContext(ctx)
+	pending := false
+
+	for i, notification := range booking.Spec.Notifications {
+		for _, event := range events {
+			if !notification.Wants(event) {
+				continue
+			}
+
+			var reminder, endAt string
+			switch event {
+			case managerv1.NotificationEventExpiring:
+				leadTime, ok := dueReminder(notification.ReminderLeadTimes(), time.Until(bookEnd))
+				if !ok {
+					continue
+				}
+				reminder = leadTime.String()
+			case managerv1.NotificationEventUpdated:
+				// Only the latest change is notified about, as it supersedes the ones before it
+				endAt = lastEndChange(booking).EndAt
+			}
+
+			delivery := notificationDelivery(&booking.Status, i, notification.Type, event, reminder, endAt)
+			if delivery.SentAt != "" || delivery.Attempts >= maxNotificationAttempts {
+				continue
+			}
+
+			delivery.Attempts++
+			e := notify.Event{Name: event, Booking: *booking, Resource: *resource}
+			if err := r.notify(ctx, notification, e); err != nil {
+				log.Error(err, "Error sending notification", "type", notification.Type, "event", event)
+				delivery.LastError = err.Error()
+				pending = pending || delivery.Attempts < maxNotificationAttempts
+				r.Recorder.Eventf(booking, corev1.EventTypeWarning, "NotificationFailed",
+					"Sending the %s notification about the %s event failed, attempt %d of %d: %v",
+					notification.Type, event, delivery.Attempts, maxNotificationAttempts, err)
+				continue
+			}
+
+			delivery.SentAt = time.Now().UTC().Format(time.RFC3339)
+			delivery.LastError = ""
+		}
+	}
+
+	return pending
+}
+
+// dueReminder returns the lead time of the reminder that is due, given the time left until the end of the booking.
+// That is the shortest lead time that isn't shorter than the time left, so reminders that were missed, like the ones
+// longer than the booking itself, are superseded by the next one instead of all being sent at once.
+func dueReminder(leadTimes []time.Duration, left time.Duration) (time.Duration, bool) {
+	if left <= 0 {
+		return 0, false
+	}
+
+	for _, leadTime := range leadTimes {
+		if left <= leadTime {
+			return leadTime, true
+		}
+	}
+
+	return 0, false
+}
+
+// notify sends a single event through the notification.
+func (r *BookingReconciler) notify(ctx context.Context, notification managerv1.Notification, event notify.Event) error {
+	secret, err := r.notificationSecret(ctx, event.Booking.Namespace, notification)
+	if err != nil {
+		return err
+	}
+
+	// Email notifications without a secret of their own use the SMTP settings of the operator
+	if notification.Type == managerv1.NotificationEmail && secret == nil && r.SMTPSecret.Name != "" {
+		if secret, err = r.secretData(ctx, r.SMTPSecret); err != nil {
+			return err
+		}
+	}
+
+	templates, err := r.notificationTemplates(ctx)
+	if err != nil {
+		return err
+	}
+
+	n, err := notify.NewNotifier(notification, secret, notify.Options{Templates: templates, SMTP: r.SMTP})
+	if err != nil {
+		return err
+	}
+
+	return n.Prepare(event).Send()
+}
+
+// notificationTemplates returns the templates of the notification content, or nil when none are configured.
+// They are read on every notification, so changes to the ConfigMap apply right away.
+func (r *BookingReconciler) notificationTemplates(ctx context.Context) (*notify.Templates, error) {
+	if r.NotificationTemplates.Name == "" {
+		return nil, nil
+	}
+
+	var configMap corev1.ConfigMap
+	if err := r.Get(ctx, r.NotificationTemplates, &configMap); err != nil {
+		return nil, err
+	}
+
+	return notify.ParseTemplates(configMap.Data)
+}
+
+// notificationDelivery returns the delivery record of the event, reminder or new end of the booking through the
+// notification at the index, adding it when missing.
+func notificationDelivery(status *managerv1.BookingStatus, index int, notificationType, event, reminder, endAt string) *managerv1.NotificationDelivery {
+	for i := range status.Notifications {
+		d := &status.Notifications[i]
+		if d.Index == index && d.Type == notificationType && d.Event == event && d.Reminder == reminder && d.EndAt == endAt {
+			return d
+		}
+	}
+
+	status.Notifications = append(status.Notifications,
+		managerv1.NotificationDelivery{Index: index, Type: notificationType, Event: event, Reminder: reminder, EndAt: endAt})
+	return &status.Notifications[len(status.Notifications)-1]
+}
+
+// notificationSecret returns the data of the Secret referenced by the notification, or nil when it doesn't reference one.
+func (r *BookingReconciler) notificationSecret(ctx context.Context, namespace string, notification managerv1.Notification) (map[string][]byte, error) {
+	if notification.SecretRef == nil {
+		return nil, nil
+	}
+
+	return r.secretData(ctx, types.NamespacedName{Namespace: namespace, Name: notification.SecretRef.Name})
+}
+
+// secretData returns the data of the named Secret.
+func (r *BookingReconciler) secretData(ctx context.Context, name types.NamespacedName) (map[string][]byte, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, name, &secret); err != nil {
+		return nil, err
+	}
+
+	return secret.Data, nil
+}
+
+// updateResource books the resource for a booking in progress, and releases it once the booking finished. The resource
+// is only updated when that changes it, and only released while it's still booked by the booking, as each update of the
+// resource reconciles the bookings of the resource again.
 func updateResource(r *BookingReconciler, ctx context.Context, rs *managerv1.Resource, booking *managerv1.Booking) {
 	log := log.FromContext(ctx)
 
+	bookedBy, bookedUntil := rs.Spec.BookedBy, rs.Spec.BookedUntil
 	if booking.Status.Status == managerv1.BookingInProgress {
-		rs.Spec.BookedBy = booking.Spec.UserID
-		rs.Spec.BookedUntil = booking.Spec.EndAt
+		// Overlapping bookings of the same user keep the resource booked until the last of them ends
+		if rs.Spec.BookedBy != booking.Spec.UserID || !endsAfter(rs.Spec.BookedUntil, booking.Spec.EndAt) {
+			bookedBy, bookedUntil = booking.Spec.UserID, booking.Spec.EndAt
+		}
 	} else if booking.Status.Status == managerv1.BookingFinished {
-		rs.Spec.BookedBy = ""
-		rs.Spec.BookedUntil = ""
+		if rs.Spec.BookedBy == booking.Spec.UserID && rs.Spec.BookedUntil == booking.Spec.EndAt {
+			bookedBy, bookedUntil = "", ""
+		}
+	}
+
+	if bookedBy == rs.Spec.BookedBy && bookedUntil == rs.Spec.BookedUntil {
+		return
+	}
+	// Only the booking that preempted the user holding the resource takes over their lock
+	if rs.Spec.PreemptedBy != rs.Spec.BookedBy {
+		rs.Spec.PreemptedBy = ""
 	}
+	rs.Spec.BookedBy, rs.Spec.BookedUntil = bookedBy, bookedUntil
 
 	err := r.Update(ctx, rs)
 	if err != nil {
 		log.Error(err, "Error updating resource spec")
+		r.Recorder.Eventf(booking, corev1.EventTypeWarning, "ResourceUpdateFailed", "Updating resource %s failed: %v", rs.Name, err)
+		return
+	}
+
+	if bookedBy == "" {
+		r.Recorder.Eventf(booking, corev1.EventTypeNormal, "ResourceReleased", "Released resource %s", rs.Name)
+	} else {
+		r.Recorder.Eventf(booking, corev1.EventTypeNormal, "ResourceBooked", "Booked resource %s for %s until %s", rs.Name, bookedBy, bookedUntil)
+	}
+}
+
+// endsAfter reports whether the end a is later than the end b. Unparsable ends are never later.
+func endsAfter(a, b string) bool {
+	aEnd, err := time.Parse(time.RFC3339, a)
+	if err != nil {
+		return false
+	}
+
+	bEnd, err := time.Parse(time.RFC3339, b)
+	if err != nil {
+		return false
+	}
+
+	return aEnd.After(bEnd)
+}
+
+// bookingsForResource maps a resource to the requests of its bookings, so that bookings notice their resource
+// starting or failing to start.
+func (r *BookingReconciler) bookingsForResource(ctx context.Context, obj client.Object) []reconcile.Request {
+	log := log.FromContext(ctx)
+
+	var bookings managerv1.BookingList
+	if err := r.List(ctx, &bookings, client.InNamespace(obj.GetNamespace()), client.MatchingFields{"spec.resource_name": obj.GetName()}); err != nil {
+		log.Error(err, "Error listing bookings of resource")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(bookings.Items))
+	for _, booking := range bookings.Items {
+		if booking.Status.Status == managerv1.BookingFinished {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: booking.Namespace, Name: booking.Name}})
+	}
+
+	return requests
+}
+
+// queuedBookingsForBooking maps a booking to the requests of the queued bookings of the same resource, so that queued
+// bookings notice the bookings ahead of them ending, being released or deleted.
+func (r *BookingReconciler) queuedBookingsForBooking(ctx context.Context, obj client.Object) []reconcile.Request {
+	log := log.FromContext(ctx)
+
+	booking, ok := obj.(*managerv1.Booking)
+	if !ok {
+		return nil
+	}
+
+	var bookings managerv1.BookingList
+	if err := r.List(ctx, &bookings, client.InNamespace(booking.Namespace), client.MatchingFields{"spec.resource_name": booking.Spec.ResourceName}); err != nil {
+		log.Error(err, "Error listing bookings of resource")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, other := range bookings.Items {
+		if other.Name != booking.Name && other.Spec.Queue {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: other.Namespace, Name: other.Name}})
+		}
 	}
+
+	return requests
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *BookingReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	ctx := context.TODO()
+	log := log.FromContext(ctx)
+
+	err := mgr.GetFieldIndexer().IndexField(ctx, &managerv1.Booking{}, "spec.resource_name", func(o client.Object) []string {
+		return []string{o.(*managerv1.Booking).Spec.ResourceName}
+	})
+	if err != nil {
+		log.Error(err, "Error indexing booking resource name field")
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&managerv1.Booking{}).
+		Watches(&managerv1.Resource{}, handler.EnqueueRequestsFromMapFunc(r.bookingsForResource)).
+		Watches(&managerv1.Booking{}, handler.EnqueueRequestsFromMapFunc(r.queuedBookingsForBooking)).
 		Complete(r)
 }
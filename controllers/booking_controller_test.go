@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"time"
 
@@ -10,8 +13,18 @@ import (
 	. "github.com/onsi/gomega"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	"github.com/kotaicode/resource-booking-operator/clients"
+	"github.com/kotaicode/resource-booking-operator/notify"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -81,8 +94,8 @@ var _ = Describe("Booking controller", func() {
 
 		AfterEach(func() {
 			// Clean up booking and resource
-			Expect(k8sClient.Delete(ctx, booking)).Should(Succeed())
-			Expect(k8sClient.Delete(ctx, resource)).Should(Succeed())
+			deleteAndWait(ctx, booking)
+			deleteAndWait(ctx, resource)
 		})
 
 		It("Should update booking status to SCHEDULED for future bookings", func() {
@@ -175,4 +188,557 @@ var _ = Describe("Booking controller", func() {
 			// TODO Check if the resource spec.booked got updated
 		})
 	})
+
+	Context("Booking notifications", func() {
+		var secret *corev1.Secret
+		var received chan string
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			received = make(chan string, 10)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				received <- req.Header.Get(notify.WebhookEventHeader)
+			}))
+
+			secret = &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "booking-webhook", Namespace: BookingNamespace},
+				StringData: map[string]string{notify.SecretURLKey: server.URL},
+			}
+			Expect(k8sClient.Create(ctx, secret)).Should(Succeed())
+
+			resource = &managerv1.Resource{
+				ObjectMeta: metav1.ObjectMeta{Name: BookingResourceName, Namespace: BookingNamespace},
+				Spec:       managerv1.ResourceSpec{Type: "ec2", Tag: "analytics"},
+			}
+			Expect(k8sClient.Create(ctx, resource)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			server.Close()
+			deleteAndWait(ctx, booking)
+			deleteAndWait(ctx, resource)
+			Expect(k8sClient.Delete(ctx, secret)).Should(Succeed())
+		})
+
+		It("Should deliver the opted in events once and record them", func() {
+			booking = &managerv1.Booking{
+				ObjectMeta: metav1.ObjectMeta{Name: BookingName, Namespace: BookingNamespace},
+				Spec: managerv1.BookingSpec{
+					StartAt:      ScheduledBookingStart,
+					EndAt:        ScheduledBookingEnd,
+					ResourceName: BookingResourceName,
+					Notifications: []managerv1.Notification{{
+						Type:      managerv1.NotificationWebhook,
+						Events:    []string{managerv1.NotificationEventScheduled, managerv1.NotificationEventEnded},
+						SecretRef: &corev1.LocalObjectReference{Name: secret.Name},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, booking)).Should(Succeed())
+
+			By("By checking that the scheduled event was delivered")
+			Eventually(received, time.Second*10).Should(Receive(Equal(managerv1.NotificationEventScheduled)))
+
+			lookupKey := types.NamespacedName{Name: BookingName, Namespace: BookingNamespace}
+			createdBooking := &managerv1.Booking{}
+			Eventually(func() ([]managerv1.NotificationDelivery, error) {
+				err := k8sClient.Get(ctx, lookupKey, createdBooking)
+				return createdBooking.Status.Notifications, err
+			}).Should(ConsistOf(And(
+				HaveField("Event", managerv1.NotificationEventScheduled),
+				HaveField("SentAt", Not(BeEmpty())),
+				HaveField("Attempts", 1),
+			)))
+
+			By("By checking that the event isn't delivered again")
+			Consistently(received, time.Second*2).ShouldNot(Receive())
+		})
+	})
+})
+
+var _ = Describe("Booking reminders", func() {
+	leadTimes := []time.Duration{5 * time.Minute, 15 * time.Minute, time.Hour}
+
+	It("Should only find the shortest reminder that isn't shorter than the time left due", func() {
+		due := func(left time.Duration) time.Duration {
+			leadTime, ok := dueReminder(leadTimes, left)
+			if !ok {
+				return 0
+			}
+			return leadTime
+		}
+
+		Expect(due(2 * time.Hour)).Should(BeZero())
+		Expect(due(50 * time.Minute)).Should(Equal(time.Hour))
+		Expect(due(15 * time.Minute)).Should(Equal(15 * time.Minute))
+		Expect(due(3 * time.Minute)).Should(Equal(5 * time.Minute))
+		Expect(due(0)).Should(BeZero())
+	})
+
+	It("Should requeue for the next start, end or reminder of the booking", func() {
+		now := time.Now()
+		booking := &managerv1.Booking{Spec: managerv1.BookingSpec{
+			Notifications: []managerv1.Notification{{
+				Type:      managerv1.NotificationEmail,
+				Reminders: []metav1.Duration{{Duration: time.Hour}, {Duration: 15 * time.Minute}},
+			}},
+		}}
+
+		By("By requeuing for the start of a scheduled booking")
+		Expect(nextReconcile(booking, now.Add(30*time.Minute), now.Add(3*time.Hour), false)).
+			Should(BeNumerically("~", 30*time.Minute, time.Second))
+
+		By("By requeuing for the next reminder of a booking in progress")
+		Expect(nextReconcile(booking, now.Add(-time.Hour), now.Add(30*time.Minute), false)).
+			Should(BeNumerically("~", 15*time.Minute, time.Second))
+
+		By("By retrying failed deliveries sooner")
+		Expect(nextReconcile(booking, now.Add(-time.Hour), now.Add(30*time.Minute), true)).
+			Should(BeNumerically("~", notificationRetryInterval, time.Second))
+
+		By("By not requeuing finished bookings")
+		Expect(nextReconcile(booking, now.Add(-2*time.Hour), now.Add(-time.Hour), false)).Should(BeZero())
+	})
+})
+
+// newFakeBookingReconciler returns a booking reconciler backed by a fake client with the objects, for the specs that
+// test the reconciler on its own, without a test environment.
+func newFakeBookingReconciler(cloud *clients.FakeCloud, objs ...client.Object) *BookingReconciler {
+	scheme := runtime.NewScheme()
+	Expect(corev1.AddToScheme(scheme)).Should(Succeed())
+	Expect(managerv1.AddToScheme(scheme)).Should(Succeed())
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&managerv1.Booking{}).
+		WithIndex(&managerv1.Booking{}, "spec.resource_name", func(o client.Object) []string {
+			return []string{o.(*managerv1.Booking).Spec.ResourceName}
+		}).Build()
+
+	return &BookingReconciler{Client: c, Scheme: scheme, Clients: clients.Clients{Fake: cloud}, Recorder: record.NewFakeRecorder(100)}
+}
+
+// rfc3339 formats the time like the start and end of bookings.
+func rfc3339(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// newTestBooking returns a booking of the ec2.analytics resource in the default namespace, for the specs to adjust.
+func newTestBooking(name, userID string, start, end time.Time) *managerv1.Booking {
+	return &managerv1.Booking{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: managerv1.BookingSpec{
+			ResourceName: "ec2.analytics",
+			UserID:       userID,
+			StartAt:      rfc3339(start),
+			EndAt:        rfc3339(end),
+		},
+	}
+}
+
+// reconcileBooking reconciles the booking, and returns it as stored afterwards.
+// deleteAndWait deletes the object from the test cluster and waits until it's gone. Bookings are only gone once the
+// controller removed their cleanup finalizer, so the next spec can create a booking with the same name.
+func deleteAndWait(ctx context.Context, obj client.Object) {
+	Expect(k8sClient.Delete(ctx, obj)).Should(Succeed())
+	Eventually(func() bool {
+		return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj))
+	}, 10*time.Second, 250*time.Millisecond).Should(BeTrue())
+}
+
+func reconcileBooking(ctx context.Context, r *BookingReconciler, booking *managerv1.Booking) *managerv1.Booking {
+	_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(booking)})
+	Expect(err).ShouldNot(HaveOccurred())
+
+	var updated managerv1.Booking
+	Expect(r.Get(ctx, client.ObjectKeyFromObject(booking), &updated)).Should(Succeed())
+	return &updated
+}
+
+var _ = Describe("Booking extensions and cancellations", func() {
+	ctx := context.Background()
+
+	var (
+		reconciler *BookingReconciler
+		booking    *managerv1.Booking
+		resource   *managerv1.Resource
+		end        time.Time
+		cloud      *clients.FakeCloud
+	)
+
+	newBookingReconciler := func(objs ...client.Object) *BookingReconciler {
+		return newFakeBookingReconciler(cloud, objs...)
+	}
+
+	resourceStatus := func() clients.ResourceStatusOutput {
+		status, err := (&clients.FakeResource{NameTag: "analytics", Cloud: cloud}).Status()
+		Expect(err).ShouldNot(HaveOccurred())
+		return status
+	}
+
+	BeforeEach(func() {
+		end = time.Now().Add(time.Hour).Truncate(time.Second)
+		booking = newTestBooking("analytics-bob", "bob", end.Add(-2*time.Hour), end)
+		resource = &managerv1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "ec2.analytics", Namespace: "default"},
+			Spec:       managerv1.ResourceSpec{Type: clients.TypeFake, Tag: "analytics", BookedBy: "bob", BookedUntil: rfc3339(end)},
+		}
+
+		cloud = clients.NewFakeCloud(clients.FakeCloudOptions{})
+		cloud.AddInstances("analytics", 2, false)
+		Expect((&clients.FakeResource{NameTag: "analytics", Cloud: cloud}).Start(
+			clients.ResourceStartInput{UID: "bob", EndAt: rfc3339(end)})).Should(Succeed())
+	})
+
+	It("Should extend the booking and the booking of its resource", func() {
+		booking.Spec.ExtendBy = &metav1.Duration{Duration: 30 * time.Minute}
+		reconciler = newBookingReconciler(booking, resource)
+
+		Expect(reconciler.applyEndRequest(ctx, booking, resource)).Should(Succeed())
+
+		Expect(booking.Spec.EndAt).Should(Equal(rfc3339(end.Add(30 * time.Minute))))
+		Expect(booking.Spec.ExtendBy).Should(BeNil())
+		Expect(booking.Status.History).Should(ConsistOf(And(
+			HaveField("Action", managerv1.BookingActionExtended),
+			HaveField("PreviousEndAt", rfc3339(end)),
+			HaveField("EndAt", booking.Spec.EndAt),
+		)))
+
+		var updated managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(resource), &updated)).Should(Succeed())
+		Expect(updated.Spec.BookedUntil).Should(Equal(booking.Spec.EndAt))
+	})
+
+	It("Should reject extensions that overlap a booking of another user", func() {
+		booking.Spec.ExtendBy = &metav1.Duration{Duration: 30 * time.Minute}
+		next := newTestBooking("analytics-alice", "alice", end.Add(15*time.Minute), end.Add(time.Hour))
+		reconciler = newBookingReconciler(booking, next, resource)
+
+		Expect(reconciler.applyEndRequest(ctx, booking, resource)).Should(Succeed())
+
+		Expect(booking.Spec.EndAt).Should(Equal(rfc3339(end)))
+		Expect(booking.Spec.ExtendBy).Should(BeNil())
+		Expect(booking.Status.History).Should(ConsistOf(And(
+			HaveField("Action", managerv1.BookingActionExtensionRejected),
+			HaveField("Message", ContainSubstring("booking analytics-alice")),
+		)))
+	})
+
+	It("Should keep the request until its history entry is written", func() {
+		booking.Spec.ExtendBy = &metav1.Duration{Duration: 30 * time.Minute}
+		reconciler = newBookingReconciler(booking, resource)
+		failStatus := true
+		reconciler.Client = interceptor.NewClient(reconciler.Client.(client.WithWatch), interceptor.Funcs{
+			SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+				if failStatus {
+					return errors.New("etcd unavailable")
+				}
+				return c.SubResource(subResource).Update(ctx, obj, opts...)
+			},
+		})
+
+		Expect(reconciler.applyEndRequest(ctx, booking, resource)).ShouldNot(Succeed())
+
+		var stored managerv1.Booking
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(booking), &stored)).Should(Succeed())
+		Expect(stored.Spec.ExtendBy).ShouldNot(BeNil())
+		Expect(stored.Spec.EndAt).Should(Equal(rfc3339(end)))
+
+		By("By recording the request once the status can be written")
+		failStatus = false
+		Expect(reconciler.applyEndRequest(ctx, &stored, resource)).Should(Succeed())
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(booking), &stored)).Should(Succeed())
+		Expect(stored.Spec.ExtendBy).Should(BeNil())
+		Expect(stored.Spec.EndAt).Should(Equal(rfc3339(end.Add(30 * time.Minute))))
+		Expect(stored.Status.History).Should(ConsistOf(HaveField("Action", managerv1.BookingActionExtended)))
+	})
+
+	It("Should apply a recorded request once more instead of deciding it again", func() {
+		booking.Generation = 2
+		booking.Spec.ExtendBy = &metav1.Duration{Duration: 30 * time.Minute}
+		reconciler = newBookingReconciler(booking, resource)
+		failUpdate := true
+		reconciler.Client = interceptor.NewClient(reconciler.Client.(client.WithWatch), interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if _, ok := obj.(*managerv1.Booking); ok && failUpdate {
+					return errors.New("etcd unavailable")
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		})
+
+		Expect(reconciler.applyEndRequest(ctx, booking, resource)).ShouldNot(Succeed())
+
+		var stored managerv1.Booking
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(booking), &stored)).Should(Succeed())
+		Expect(stored.Spec.ExtendBy).ShouldNot(BeNil())
+		Expect(stored.Status.History).Should(ConsistOf(HaveField("Generation", int64(2))))
+
+		By("By clearing the request with the recorded outcome, even though the resource is booked by now")
+		Expect(reconciler.Create(ctx, newTestBooking("analytics-alice", "alice", end.Add(15*time.Minute), end.Add(time.Hour)))).Should(Succeed())
+		failUpdate = false
+		Expect(reconciler.applyEndRequest(ctx, &stored, resource)).Should(Succeed())
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(booking), &stored)).Should(Succeed())
+		Expect(stored.Spec.ExtendBy).Should(BeNil())
+		Expect(stored.Spec.EndAt).Should(Equal(rfc3339(end.Add(30 * time.Minute))))
+		Expect(stored.Status.History).Should(ConsistOf(HaveField("Action", managerv1.BookingActionExtended)))
+	})
+
+	It("Should release the booking early", func() {
+		booking.Spec.Release = true
+		reconciler = newBookingReconciler(booking, resource)
+
+		Expect(reconciler.applyEndRequest(ctx, booking, resource)).Should(Succeed())
+
+		releasedAt, err := time.Parse(time.RFC3339, booking.Spec.EndAt)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(releasedAt).Should(BeTemporally("~", time.Now(), 2*time.Second))
+		Expect(booking.Spec.Release).Should(BeFalse())
+		Expect(booking.Status.History).Should(ConsistOf(HaveField("Action", managerv1.BookingActionReleased)))
+
+		By("By handing back the resource right away")
+		Expect(resource.Spec.BookedBy).Should(BeEmpty())
+		Expect(resource.Spec.BookedUntil).Should(BeEmpty())
+		Expect(resourceStatus().LockedBy).Should(BeEmpty())
+	})
+
+	It("Should notify about each change of the end of the booking once", func() {
+		var events []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			events = append(events, req.Header.Get(notify.WebhookEventHeader))
+		}))
+		defer server.Close()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+			Data:       map[string][]byte{notify.SecretURLKey: []byte(server.URL)},
+		}
+		booking.Spec.ExtendBy = &metav1.Duration{Duration: 30 * time.Minute}
+		booking.Spec.Notifications = []managerv1.Notification{{
+			Type:      managerv1.NotificationWebhook,
+			Events:    []string{managerv1.NotificationEventUpdated},
+			SecretRef: &corev1.LocalObjectReference{Name: secret.Name},
+		}}
+		reconciler = newBookingReconciler(booking, resource, secret)
+
+		booking = reconcileBooking(ctx, reconciler, booking)
+		Expect(events).Should(Equal([]string{managerv1.NotificationEventUpdated}))
+
+		By("By not notifying about the same change again")
+		booking = reconcileBooking(ctx, reconciler, booking)
+		Expect(events).Should(HaveLen(1))
+
+		By("By notifying about the next extension")
+		booking.Spec.ExtendBy = &metav1.Duration{Duration: 15 * time.Minute}
+		Expect(reconciler.Update(ctx, booking)).Should(Succeed())
+		booking = reconcileBooking(ctx, reconciler, booking)
+		Expect(events).Should(HaveLen(2))
+		Expect(booking.Status.Notifications).Should(ConsistOf(
+			HaveField("EndAt", rfc3339(end.Add(30*time.Minute))),
+			HaveField("EndAt", rfc3339(end.Add(45*time.Minute))),
+		))
+	})
+
+	It("Should hand back the resource and notify when a booking in progress is deleted", func() {
+		var events []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			events = append(events, req.Header.Get(notify.WebhookEventHeader))
+		}))
+		defer server.Close()
+
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+			Data:       map[string][]byte{notify.SecretURLKey: []byte(server.URL)},
+		}
+		booking.Finalizers = []string{bookingFinalizer}
+		booking.Spec.Notifications = []managerv1.Notification{{
+			Type:      managerv1.NotificationWebhook,
+			Events:    []string{managerv1.NotificationEventCancelled},
+			SecretRef: &corev1.LocalObjectReference{Name: secret.Name},
+		}}
+		reconciler = newBookingReconciler(booking, resource, secret)
+
+		Expect(reconciler.Delete(ctx, booking)).Should(Succeed())
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(booking)})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		var deleted managerv1.Booking
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(booking), &deleted)).ShouldNot(Succeed())
+		Expect(events).Should(Equal([]string{managerv1.NotificationEventCancelled}))
+
+		var updated managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(resource), &updated)).Should(Succeed())
+		Expect(updated.Spec.BookedBy).Should(BeEmpty())
+		Expect(resourceStatus().LockedBy).Should(BeEmpty())
+	})
+
+	It("Should leave the resource to other bookings when a booking is deleted", func() {
+		booking.Finalizers = []string{bookingFinalizer}
+		resource.Spec.BookedBy = "alice"
+		reconciler = newBookingReconciler(booking, resource)
+
+		Expect(reconciler.Delete(ctx, booking)).Should(Succeed())
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(booking)})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		var updated managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(resource), &updated)).Should(Succeed())
+		Expect(updated.Spec.BookedBy).Should(Equal("alice"))
+		Expect(resourceStatus().LockedBy).Should(Equal("bob"))
+	})
+})
+
+var _ = Describe("Booking queue", func() {
+	ctx := context.Background()
+
+	var (
+		reconciler *BookingReconciler
+		holder     *managerv1.Booking
+		resource   *managerv1.Resource
+		now        time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now().Truncate(time.Second)
+		holder = newTestBooking("analytics-alice", "alice", now.Add(-time.Hour), now.Add(time.Hour))
+		holder.Status.Status = managerv1.BookingInProgress
+		resource = &managerv1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "ec2.analytics", Namespace: "default"},
+			Spec:       managerv1.ResourceSpec{Type: clients.TypeFake, Tag: "analytics", BookedBy: "alice", BookedUntil: holder.Spec.EndAt},
+		}
+	})
+
+	It("Should keep a queued booking waiting behind the holder of the resource", func() {
+		queued := newTestBooking("analytics-bob", "bob", now.Add(-30*time.Minute), now.Add(2*time.Hour))
+		queued.Spec.Queue = true
+		reconciler = newFakeBookingReconciler(nil, holder, resource, queued)
+
+		queued = reconcileBooking(ctx, reconciler, queued)
+		Expect(queued.Status.Status).Should(Equal(managerv1.BookingQueued))
+		Expect(queued.Spec.Queue).Should(BeTrue())
+
+		var unchanged managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(resource), &unchanged)).Should(Succeed())
+		Expect(unchanged.Spec.BookedBy).Should(Equal("alice"))
+	})
+
+	It("Should promote a queued booking for the rest of its window once the holder ends", func() {
+		holder.Status.Status = managerv1.BookingFinished
+		resource.Spec.BookedBy, resource.Spec.BookedUntil = "", ""
+		queued := newTestBooking("analytics-bob", "bob", now.Add(-30*time.Minute), now.Add(2*time.Hour))
+		queued.Spec.Queue = true
+		queued.Status.Status = managerv1.BookingQueued
+		reconciler = newFakeBookingReconciler(nil, holder, resource, queued)
+
+		queued = reconcileBooking(ctx, reconciler, queued)
+		Expect(queued.Spec.Queue).Should(BeFalse())
+		Expect(queued.Status.Status).Should(Equal(managerv1.BookingInProgress))
+		Expect(time.Parse(time.RFC3339, queued.Spec.StartAt)).Should(BeTemporally("~", time.Now(), 2*time.Second))
+		Expect(queued.Status.History).Should(ConsistOf(HaveField("Action", managerv1.BookingActionPromoted)))
+
+		var booked managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(resource), &booked)).Should(Succeed())
+		Expect(booked.Spec.BookedBy).Should(Equal("bob"))
+		Expect(booked.Spec.BookedUntil).Should(Equal(queued.Spec.EndAt))
+	})
+
+	It("Should promote queued bookings in the order they were queued", func() {
+		holder.Status.Status = managerv1.BookingFinished
+		resource.Spec.BookedBy, resource.Spec.BookedUntil = "", ""
+		first := newTestBooking("analytics-bob", "bob", now.Add(-30*time.Minute), now.Add(2*time.Hour))
+		first.Spec.Queue = true
+		first.CreationTimestamp = metav1.NewTime(now.Add(-20 * time.Minute))
+		first.Status.Status = managerv1.BookingQueued
+		second := newTestBooking("analytics-carol", "carol", now.Add(-30*time.Minute), now.Add(2*time.Hour))
+		second.Spec.Queue = true
+		second.CreationTimestamp = metav1.NewTime(now.Add(-10 * time.Minute))
+		second.Status.Status = managerv1.BookingQueued
+		reconciler = newFakeBookingReconciler(nil, holder, resource, first, second)
+
+		Expect(reconcileBooking(ctx, reconciler, second).Status.Status).Should(Equal(managerv1.BookingQueued))
+		Expect(reconcileBooking(ctx, reconciler, first).Status.Status).Should(Equal(managerv1.BookingInProgress))
+		Expect(reconcileBooking(ctx, reconciler, second).Status.Status).Should(Equal(managerv1.BookingQueued))
+	})
+
+	It("Should promote queued bookings right away when nothing blocks them", func() {
+		queued := newTestBooking("analytics-bob", "bob", now.Add(2*time.Hour), now.Add(3*time.Hour))
+		queued.Spec.Queue = true
+		reconciler = newFakeBookingReconciler(nil, holder, resource, queued)
+
+		queued = reconcileBooking(ctx, reconciler, queued)
+		Expect(queued.Spec.Queue).Should(BeFalse())
+		Expect(queued.Spec.StartAt).Should(Equal(rfc3339(now.Add(2 * time.Hour))))
+		Expect(queued.Status.Status).Should(Equal(managerv1.BookingScheduled))
+		Expect(queued.Status.History).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Booking preemption", func() {
+	ctx := context.Background()
+
+	var (
+		reconciler *BookingReconciler
+		analytics  *managerv1.Resource
+		now        time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Now().Truncate(time.Second)
+		analytics = &managerv1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "ec2.analytics", Namespace: "default"},
+			Spec:       managerv1.ResourceSpec{Type: clients.TypeFake, Tag: "analytics"},
+		}
+	})
+
+	It("Should preempt an overlapping booking with a lower priority and take over its resource", func() {
+		low := newTestBooking("analytics-alice", "alice", now.Add(-time.Hour), now.Add(2*time.Hour))
+		low.Status.Status = managerv1.BookingInProgress
+		analytics.Spec.BookedBy, analytics.Spec.BookedUntil = "alice", low.Spec.EndAt
+		high := newTestBooking("analytics-release", "bob", now.Add(-time.Minute), now.Add(time.Hour))
+		high.Spec.Priority = 10
+		reconciler = newFakeBookingReconciler(nil, analytics, low, high)
+
+		Expect(reconcileBooking(ctx, reconciler, high).Status.Status).Should(Equal(managerv1.BookingInProgress))
+
+		var preempted managerv1.Booking
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(low), &preempted)).Should(Succeed())
+		Expect(preempted.Status.Status).Should(Equal(managerv1.BookingPreempted))
+		Expect(preempted.Status.History).Should(ConsistOf(And(
+			HaveField("Action", managerv1.BookingActionPreempted),
+			HaveField("Message", ContainSubstring("analytics-release")),
+		)))
+
+		var resource managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(analytics), &resource)).Should(Succeed())
+		Expect(resource.Spec.BookedBy).Should(Equal("bob"))
+		Expect(resource.Spec.BookedUntil).Should(Equal(high.Spec.EndAt))
+		Expect(resource.Spec.PreemptedBy).Should(Equal("alice"))
+
+		// The preempted booking stays preempted, and doesn't book the resource again
+		updated := reconcileBooking(ctx, reconciler, &preempted)
+		Expect(updated.Status.Status).Should(Equal(managerv1.BookingPreempted))
+		Expect(updated.Finalizers).Should(BeEmpty())
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(analytics), &resource)).Should(Succeed())
+		Expect(resource.Spec.BookedBy).Should(Equal("bob"))
+	})
+
+	It("Should leave bookings with the same priority, and the ones that don't overlap, alone", func() {
+		high := newTestBooking("analytics-release", "bob", now.Add(-time.Minute), now.Add(time.Hour))
+		high.Spec.Priority = 10
+		same := newTestBooking("analytics-carol", "carol", now.Add(30*time.Minute), now.Add(2*time.Hour))
+		same.Spec.Priority = 10
+		later := newTestBooking("analytics-alice", "alice", now.Add(time.Hour), now.Add(2*time.Hour))
+		reconciler = newFakeBookingReconciler(nil, analytics, high, same, later)
+
+		reconcileBooking(ctx, reconciler, high)
+
+		for _, booking := range []*managerv1.Booking{same, later} {
+			var unchanged managerv1.Booking
+			Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(booking), &unchanged)).Should(Succeed())
+			Expect(unchanged.Status.Status).ShouldNot(Equal(managerv1.BookingPreempted))
+		}
+
+		var resource managerv1.Resource
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(analytics), &resource)).Should(Succeed())
+		Expect(resource.Spec.PreemptedBy).Should(BeEmpty())
+	})
 })
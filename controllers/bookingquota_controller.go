@@ -0,0 +1,133 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+// BookingQuotaReconciler reconciles a BookingQuota object
+type BookingQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookingquotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookingquotas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookingquotas/finalizers,verbs=update
+//+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookings,verbs=get;list;watch
+
+// Reconcile reports the current usage of the booking quota in its status. The quota is reconciled again when the
+// bookings of its users change, and when one of them starts or ends, or the week is over.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.13.0/pkg/reconcile
+func (r *BookingQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var quota managerv1.BookingQuota
+	if err := r.Get(ctx, req.NamespacedName, &quota); err != nil {
+		log.Error(err, "Error getting booking quota")
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	var bookings managerv1.BookingList
+	if err := r.List(ctx, &bookings, client.InNamespace(quota.Namespace)); err != nil {
+		log.Error(err, "Error listing bookings")
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	usage := quota.Usage(bookings.Items, now)
+	if !equality.Semantic.DeepEqual(quota.Status, usage) {
+		quota.Status = usage
+		if err := r.Status().Update(ctx, &quota); err != nil {
+			log.Error(err, "Error updating booking quota status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: nextUsageChange(&quota, bookings.Items, now).Sub(now)}, nil
+}
+
+// nextUsageChange returns when the usage of the quota changes next, without the bookings changing: when one of the
+// bookings of its users starts or ends, or else at the start of the next week.
+func nextUsageChange(quota *managerv1.BookingQuota, bookings []managerv1.Booking, now time.Time) time.Time {
+	next := managerv1.WeekStart(now).AddDate(0, 0, 7)
+	consider := func(t time.Time) {
+		if t.After(now) && t.Before(next) {
+			next = t
+		}
+	}
+
+	for i := range bookings {
+		if !quota.AppliesTo(bookings[i].Spec.UserID) {
+			continue
+		}
+		if start, end, err := bookings[i].Window(); err == nil {
+			consider(start)
+			consider(end)
+		}
+	}
+
+	return next
+}
+
+// quotasForBooking maps a booking to the requests of the booking quotas in its namespace that apply to its user.
+func (r *BookingQuotaReconciler) quotasForBooking(ctx context.Context, obj client.Object) []reconcile.Request {
+	log := log.FromContext(ctx)
+
+	booking, ok := obj.(*managerv1.Booking)
+	if !ok {
+		return nil
+	}
+
+	var quotas managerv1.BookingQuotaList
+	if err := r.List(ctx, &quotas, client.InNamespace(booking.Namespace)); err != nil {
+		log.Error(err, "Error listing booking quotas")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, quota := range quotas.Items {
+		if quota.AppliesTo(booking.Spec.UserID) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: quota.Namespace, Name: quota.Name}})
+		}
+	}
+
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BookingQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&managerv1.BookingQuota{}).
+		Watches(&managerv1.Booking{}, handler.EnqueueRequestsFromMapFunc(r.quotasForBooking)).
+		Complete(r)
+}
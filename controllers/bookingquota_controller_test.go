@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Booking quota usage", func() {
+	ctx := context.Background()
+
+	It("Should report the usage of the quota, and check it again when the next booking starts or ends", func() {
+		now := time.Now().Truncate(time.Second)
+		quota := &managerv1.BookingQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "default"},
+			Spec:       managerv1.BookingQuotaSpec{Users: []string{"bob", "carol"}},
+		}
+		running := newTestBooking("analytics-bob", "bob", now.Add(-time.Minute), now.Add(30*time.Minute))
+		other := newTestBooking("analytics-alice", "alice", now.Add(-time.Minute), now.Add(10*time.Minute))
+
+		scheme := runtime.NewScheme()
+		Expect(managerv1.AddToScheme(scheme)).Should(Succeed())
+		r := &BookingQuotaReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(quota, running, other).WithStatusSubresource(quota).Build(),
+			Scheme: scheme,
+		}
+
+		result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(quota)})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(quota), quota)).Should(Succeed())
+		Expect(quota.Status.ConcurrentBookings).Should(Equal(int32(1)))
+		Expect(quota.Status.WeekStart).Should(Equal(rfc3339(managerv1.WeekStart(now))))
+		Expect(quota.Status.BookedThisWeek.Duration).Should(BeNumerically(">", 0))
+
+		weekEnd := managerv1.WeekStart(now).AddDate(0, 0, 7)
+		Expect(result.RequeueAfter).Should(BeNumerically("~", min(30*time.Minute, weekEnd.Sub(now)), 2*time.Second))
+	})
+
+	It("Should map bookings to the quotas of their users", func() {
+		quota := &managerv1.BookingQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "default"},
+			Spec:       managerv1.BookingQuotaSpec{Users: []string{"bob"}},
+		}
+		scheme := runtime.NewScheme()
+		Expect(managerv1.AddToScheme(scheme)).Should(Succeed())
+		r := &BookingQuotaReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(quota).Build(), Scheme: scheme}
+
+		now := time.Now()
+		Expect(r.quotasForBooking(ctx, newTestBooking("analytics-bob", "bob", now, now.Add(time.Hour)))).Should(HaveLen(1))
+		Expect(r.quotasForBooking(ctx, newTestBooking("analytics-alice", "alice", now, now.Add(time.Hour)))).Should(BeEmpty())
+	})
+})
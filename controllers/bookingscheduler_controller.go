@@ -22,7 +22,9 @@ import (
 	"time"
 
 	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -33,13 +35,15 @@ import (
 // BookingSchedulerReconciler reconciles a BookingScheduler object
 type BookingSchedulerReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookingschedulers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookingschedulers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookingschedulers/finalizers,verbs=update
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=bookings,verbs=create
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.13.0/pkg/reconcile
@@ -59,6 +63,7 @@ func (r *BookingSchedulerReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	schedule, err := cron.ParseStandard(bookingScheduler.Spec.Schedule)
 	if err != nil {
 		log.Error(err, "Error parsing schedule", "schedule", bookingScheduler.Spec.Schedule)
+		r.Recorder.Eventf(&bookingScheduler, corev1.EventTypeWarning, "InvalidSchedule", "Parsing schedule %q failed: %v", bookingScheduler.Spec.Schedule, err)
 		return ctrl.Result{}, err
 	}
 
@@ -80,8 +85,11 @@ func (r *BookingSchedulerReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			// NOTE: Slight race condition concerns? Check if the booking already exists by name?
 			if err := r.Create(ctx, &booking); err != nil {
 				log.Error(err, "Error creating booking")
+				r.Recorder.Eventf(&bookingScheduler, corev1.EventTypeWarning, "BookingCreateFailed", "Creating booking %s failed: %v", booking.Name, err)
 				return ctrl.Result{}, err
 			}
+			r.Recorder.Eventf(&bookingScheduler, corev1.EventTypeNormal, "BookingCreated", "Created booking %s from %s until %s",
+				booking.Name, booking.Spec.StartAt, booking.Spec.EndAt)
 		}
 	}
 
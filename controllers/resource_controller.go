@@ -18,11 +18,15 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
 	"github.com/kotaicode/resource-booking-operator/clients"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -32,11 +36,16 @@ import (
 type ResourceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Clients are the cloud and cluster clients that the resources are managed with.
+	Clients  clients.Clients
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=resources,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=resources/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=resources/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -55,7 +64,7 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	cloudResource, err := clients.ResourceFactory(resource.Spec.Type, resource.Spec.Tag)
+	cloudResource, err := clients.ResourceFactory(resource.Spec.Type, resource.Spec.Tag, r.Clients)
 	if err != nil {
 		log.Error(err, err.Error())
 		return ctrl.Result{}, err
@@ -76,30 +85,60 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		status = clients.StatusPending
 	}
 
+	previousStatus := resource.Status.Status
 	resource.Status = managerv1.ResourceStatus{
-		LockedBy:    rStat.LockedBy,
-		LockedUntil: rStat.LockedUntil,
-		Instances:   rStat.Available,
-		Running:     rStat.Running,
-		Status:      status,
+		LockedBy:        rStat.LockedBy,
+		LockedUntil:     rStat.LockedUntil,
+		Instances:       rStat.Available,
+		Running:         rStat.Running,
+		Status:          status,
+		AutoRestarts:    resource.Status.AutoRestarts,
+		LastAutoRestart: resource.Status.LastAutoRestart,
 	}
 
 	if resource.Spec.BookedUntil != "" {
-		if status != clients.StatusRunning {
-			startInput := clients.ResourceStartInput{UID: resource.Spec.BookedBy, EndAt: resource.Spec.BookedUntil}
+		// Starting running instances again only updates their lock, like after the booking was extended,
+		// or hands it over from the user of a preempted booking
+		lockOutdated := lockOutdated(rStat.LockedUntil, resource.Spec.BookedUntil)
+		preempted := resource.Spec.PreemptedBy != "" && rStat.LockedBy == resource.Spec.PreemptedBy
+		if status != clients.StatusRunning || lockOutdated || preempted {
+			startInput := clients.ResourceStartInput{UID: resource.Spec.BookedBy, EndAt: resource.Spec.BookedUntil, Preempts: resource.Spec.PreemptedBy}
 			if err := cloudResource.Start(startInput); err != nil {
 				log.Error(err, "Error starting resource instances")
+				resource.Status.StartError = err.Error()
+				r.recordActionError(&resource, "StartFailed", err)
+			} else if status == clients.StatusStopped {
+				r.Recorder.Eventf(&resource, corev1.EventTypeNormal, "Starting",
+					"Starting instances booked by %s until %s", resource.Spec.BookedBy, resource.Spec.BookedUntil)
+			} else if preempted {
+				r.Recorder.Eventf(&resource, corev1.EventTypeNormal, "LockHandedOver",
+					"Handed the lock of the instances over from %s to %s until %s", resource.Spec.PreemptedBy,
+					resource.Spec.BookedBy, resource.Spec.BookedUntil)
+			} else if lockOutdated {
+				r.Recorder.Eventf(&resource, corev1.EventTypeNormal, "LockUpdated",
+					"Locked instances for %s until %s", resource.Spec.BookedBy, resource.Spec.BookedUntil)
 			}
 		}
 	} else {
-		if status == clients.StatusRunning {
+		// Instances started by the cloud provider are stopped one by one, as they don't make the whole resource run
+		autoStopped := r.stopAutoStarted(ctx, &resource, cloudResource)
+
+		if status == clients.StatusRunning && !autoStopped {
 			stopInput := clients.ResourceStopInput{UID: resource.Spec.BookedBy}
 			if err := cloudResource.Stop(stopInput); err != nil {
 				log.Error(err, "Error stopping resource instances")
+				r.recordActionError(&resource, "StopFailed", err)
+			} else {
+				r.Recorder.Event(&resource, corev1.EventTypeNormal, "Stopping", "Stopping instances, as the resource isn't booked")
 			}
 		}
 	}
 
+	if previousStatus != "" && previousStatus != status {
+		r.Recorder.Eventf(&resource, corev1.EventTypeNormal, "StatusChanged",
+			"Resource is %s, with %d of %d instances running", status, rStat.Running, rStat.Available)
+	}
+
 	err = r.Status().Update(ctx, &resource)
 	if err != nil {
 		log.Error(err, "Error updating resource status")
@@ -110,6 +149,55 @@ func (r *ResourceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return ctrl.Result{RequeueAfter: time.Duration(time.Second * 15)}, nil
 }
 
+// lockOutdated reports whether the lock of the instances is missing, or ends at another time than the booking of the resource.
+func lockOutdated(lockedUntil, bookedUntil string) bool {
+	booked, err := time.Parse(time.RFC3339, bookedUntil)
+	if err != nil {
+		return false
+	}
+
+	locked, err := time.Parse(time.RFC3339, lockedUntil)
+	return err != nil || !locked.Equal(booked)
+}
+
+// recordActionError records a failure to start or stop the instances of the resource. Failures because the resource
+// is locked by another user are recorded as lock conflicts.
+func (r *ResourceReconciler) recordActionError(resource *managerv1.Resource, reason string, err error) {
+	var locked *clients.LockedError
+	if errors.As(err, &locked) {
+		reason = "LockConflict"
+	}
+
+	r.Recorder.Event(resource, corev1.EventTypeWarning, reason, err.Error())
+}
+
+// stopAutoStarted stops the instances of an unbooked resource that the cloud provider started on its own,
+// and records them on the resource. It reports whether any instance was stopped.
+func (r *ResourceReconciler) stopAutoStarted(ctx context.Context, resource *managerv1.Resource, cloudResource clients.CloudResource) bool {
+	log := log.FromContext(ctx)
+
+	autoStarted, ok := cloudResource.(clients.AutoStartedResource)
+	if !ok {
+		return false
+	}
+
+	stopped, err := autoStarted.StopAutoStarted()
+	if err != nil {
+		log.Error(err, "Error stopping auto-started resource instances")
+	}
+	if len(stopped) == 0 {
+		return false
+	}
+
+	log.Info("stopped auto-started resource instances", "instances", stopped)
+	resource.Status.AutoRestarts += len(stopped)
+	resource.Status.LastAutoRestart = time.Now().UTC().Format(time.RFC3339)
+	r.Recorder.Eventf(resource, corev1.EventTypeWarning, "AutoStarted",
+		"Stopped instances started by the cloud provider while the resource wasn't booked: %s", strings.Join(stopped, ", "))
+
+	return true
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ResourceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"os"
 	"time"
 
@@ -9,8 +10,16 @@ import (
 	. "github.com/onsi/gomega"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	"github.com/kotaicode/resource-booking-operator/clients"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -68,4 +77,324 @@ var _ = Describe("Resource controller", func() {
 		})
 		// TODO: The case where booked is true
 	})
+
+	Context("Kubernetes workload resources", func() {
+		const (
+			WorkloadResourceName = "k8s-workload.analytics"
+			WorkloadTag          = "workload-analytics"
+			DeploymentName       = "analytics-api"
+		)
+
+		var deployment *appsv1.Deployment
+		var resource *managerv1.Resource
+
+		deploymentLookupKey := types.NamespacedName{Name: DeploymentName, Namespace: ResourceNamespace}
+		resourceLookupKey := types.NamespacedName{Name: WorkloadResourceName, Namespace: ResourceNamespace}
+
+		// setReadyReplicas stands in for the deployment controller, which doesn't run in the test environment.
+		setReadyReplicas := func(ready int32) {
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, deploymentLookupKey, deployment); err != nil {
+					return err
+				}
+				deployment.Status.Replicas = ready
+				deployment.Status.ReadyReplicas = ready
+				return k8sClient.Status().Update(ctx, deployment)
+			}, timeout, interval).Should(Succeed())
+		}
+
+		getReplicas := func() (int32, error) {
+			if err := k8sClient.Get(ctx, deploymentLookupKey, deployment); err != nil {
+				return -1, err
+			}
+			return *deployment.Spec.Replicas, nil
+		}
+
+		BeforeEach(func() {
+			replicas := int32(2)
+			labels := map[string]string{"app": DeploymentName, "resource-booking-application": WorkloadTag}
+			deployment = &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      DeploymentName,
+					Namespace: ResourceNamespace,
+					Labels:    labels,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": DeploymentName}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "api", Image: "nginx"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).Should(Succeed())
+			setReadyReplicas(2)
+
+			resource = &managerv1.Resource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      WorkloadResourceName,
+					Namespace: ResourceNamespace,
+				},
+				Spec: managerv1.ResourceSpec{
+					Tag:  WorkloadTag,
+					Type: "k8s-workload",
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, resource)).Should(Succeed())
+			Expect(k8sClient.Delete(ctx, deployment)).Should(Succeed())
+		})
+
+		It("Scales unbooked workloads to zero and restores them when booked", func() {
+			By("By waiting for the unbooked deployment to be scaled down")
+			Eventually(getReplicas, timeout, interval).Should(BeZero())
+			Expect(deployment.Annotations).Should(HaveKeyWithValue("resource-booking-replicas", "2"))
+			setReadyReplicas(0)
+
+			By("By booking the resource")
+			bookedUntil := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, resourceLookupKey, resource); err != nil {
+					return err
+				}
+				resource.Spec.BookedBy = "test"
+				resource.Spec.BookedUntil = bookedUntil
+				return k8sClient.Update(ctx, resource)
+			}, timeout, interval).Should(Succeed())
+
+			By("By waiting for the deployment to be scaled back up")
+			Eventually(getReplicas, timeout, interval).Should(Equal(int32(2)))
+			Expect(deployment.Annotations).ShouldNot(HaveKey("resource-booking-replicas"))
+			Expect(deployment.Annotations).Should(HaveKeyWithValue("resource-booking-locked-by", "test"))
+
+			By("By checking the desired and ready replicas in the resource status")
+			Eventually(func() (managerv1.ResourceStatus, error) {
+				err := k8sClient.Get(ctx, resourceLookupKey, resource)
+				return resource.Status, err
+			}, timeout, interval).Should(And(
+				HaveField("Instances", 2),
+				HaveField("Running", 0),
+				HaveField("LockedBy", "test"),
+			))
+		})
+	})
+
+	Context("Fake resources", func() {
+		const (
+			FakeResourceName = "fake.analytics"
+			FakeTag          = "fake-analytics"
+		)
+
+		var resource *managerv1.Resource
+		resourceLookupKey := types.NamespacedName{Name: FakeResourceName, Namespace: ResourceNamespace}
+
+		getStatus := func() (managerv1.ResourceStatus, error) {
+			err := k8sClient.Get(ctx, resourceLookupKey, resource)
+			return resource.Status, err
+		}
+
+		book := func(bookedBy, bookedUntil string) {
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, resourceLookupKey, resource); err != nil {
+					return err
+				}
+				resource.Spec.BookedBy = bookedBy
+				resource.Spec.BookedUntil = bookedUntil
+				return k8sClient.Update(ctx, resource)
+			}, timeout, interval).Should(Succeed())
+		}
+
+		BeforeEach(func() {
+			fakeCloud.Reset()
+			fakeCloud.AddInstances(FakeTag, 2, false)
+
+			resource = &managerv1.Resource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      FakeResourceName,
+					Namespace: ResourceNamespace,
+				},
+				Spec: managerv1.ResourceSpec{
+					Tag:  FakeTag,
+					Type: clients.TypeFake,
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(k8sClient.Delete(ctx, resource)).Should(Succeed())
+		})
+
+		It("Starts the instances of a booked resource and stops them once it is released", func() {
+			By("By checking that the unbooked instances are stopped")
+			Eventually(getStatus, timeout, interval).Should(And(
+				HaveField("Instances", 2),
+				HaveField("Status", clients.StatusStopped),
+			))
+
+			By("By booking the resource")
+			book("test", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			Eventually(getStatus, timeout, interval).Should(And(
+				HaveField("Status", clients.StatusRunning),
+				HaveField("LockedBy", "test"),
+			))
+
+			By("By releasing the resource")
+			book("", "")
+			Eventually(getStatus, timeout, interval).Should(And(
+				HaveField("Running", 0),
+				HaveField("Status", clients.StatusStopped),
+				HaveField("LockedBy", ""),
+			))
+		})
+
+		It("Runs the instances for the user of a booking until the booking ends", func() {
+			end := time.Now().Add(8 * time.Second).UTC().Truncate(time.Second)
+			booking := &managerv1.Booking{
+				ObjectMeta: metav1.ObjectMeta{Name: "fake-analytics-booking", Namespace: ResourceNamespace},
+				Spec: managerv1.BookingSpec{
+					ResourceName: FakeResourceName,
+					UserID:       "alice",
+					StartAt:      time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+					EndAt:        end.Format(time.RFC3339),
+				},
+			}
+			Expect(k8sClient.Create(ctx, booking)).Should(Succeed())
+			defer func() {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, booking))).Should(Succeed())
+			}()
+
+			By("By checking that the booked instances run locked for the user")
+			Eventually(getStatus, timeout, interval).Should(And(
+				HaveField("Status", clients.StatusRunning),
+				HaveField("Running", 2),
+				HaveField("LockedBy", "alice"),
+				HaveField("LockedUntil", end.Format(time.RFC3339)),
+			))
+
+			By("By checking that the instances stop once the booking ended")
+			Eventually(getStatus, time.Until(end)+timeout, interval).Should(And(
+				HaveField("Status", clients.StatusStopped),
+				HaveField("Running", 0),
+				HaveField("LockedBy", ""),
+			))
+		})
+
+		It("Keeps the resource stopped while starting it fails", func() {
+			fakeCloud.InjectFailure(clients.FakeStart, FakeTag, errors.New("insufficient capacity"))
+			defer fakeCloud.InjectFailure(clients.FakeStart, FakeTag, nil)
+
+			book("test", time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+			Consistently(getStatus, 3*time.Second, interval).Should(HaveField("Status", clients.StatusStopped))
+		})
+	})
+})
+
+var _ = Describe("Resource events", func() {
+	It("Records lock conflicts apart from other failures", func() {
+		recorder := record.NewFakeRecorder(2)
+		r := &ResourceReconciler{Recorder: recorder}
+		resource := &managerv1.Resource{ObjectMeta: metav1.ObjectMeta{Name: "ec2.analytics", Namespace: "default"}}
+
+		r.recordActionError(resource, "StartFailed", &clients.LockedError{LockedBy: "alice", LockedUntil: "2030-01-10T12:00:00Z"})
+		r.recordActionError(resource, "StartFailed", errors.New("insufficient capacity"))
+
+		Expect(<-recorder.Events).To(Equal("Warning LockConflict Resource is locked by alice. The lock expires at 2030-01-10T12:00:00Z."))
+		Expect(<-recorder.Events).To(Equal("Warning StartFailed insufficient capacity"))
+	})
+})
+
+var _ = Describe("Resource locks", func() {
+	It("Should only find locks missing or ending at another time than the booking outdated", func() {
+		Expect(lockOutdated("2030-01-10T12:00:00Z", "2030-01-10T12:00:00Z")).Should(BeFalse())
+		Expect(lockOutdated("2030-01-10T13:00:00+01:00", "2030-01-10T12:00:00Z")).Should(BeFalse())
+		Expect(lockOutdated("2030-01-10T12:00:00Z", "2030-01-10T12:30:00Z")).Should(BeTrue())
+		Expect(lockOutdated("", "2030-01-10T12:00:00Z")).Should(BeTrue())
+		Expect(lockOutdated("", "")).Should(BeFalse())
+	})
+})
+
+var _ = Describe("Resource preemption", func() {
+	It("Should hand the lock of the instances over from the preempted user", func() {
+		ctx := context.Background()
+		cloud := clients.NewFakeCloud(clients.FakeCloudOptions{})
+		cloud.AddInstances("staging", 1, false)
+		aliceEnd := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+		bobEnd := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		Expect((&clients.FakeResource{NameTag: "staging", Cloud: cloud}).Start(clients.ResourceStartInput{UID: "alice", EndAt: aliceEnd})).Should(Succeed())
+
+		resource := &managerv1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "fake.staging", Namespace: "default"},
+			Spec:       managerv1.ResourceSpec{Type: clients.TypeFake, Tag: "staging", BookedBy: "bob", BookedUntil: bobEnd, PreemptedBy: "alice"},
+		}
+		scheme := runtime.NewScheme()
+		Expect(managerv1.AddToScheme(scheme)).Should(Succeed())
+		recorder := record.NewFakeRecorder(10)
+		r := &ResourceReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(resource).WithStatusSubresource(resource).Build(),
+			Scheme:   scheme,
+			Clients:  clients.Clients{Fake: cloud},
+			Recorder: recorder,
+		}
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(resource)})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(resource), resource)).Should(Succeed())
+		Expect(resource.Status.StartError).Should(BeEmpty())
+		rst, err := (&clients.FakeResource{NameTag: "staging", Cloud: cloud}).Status()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rst.LockedBy).Should(Equal("bob"))
+		Expect(rst.LockedUntil).Should(Equal(bobEnd))
+		Expect(recorder.Events).Should(Receive(HavePrefix("Normal LockHandedOver")))
+	})
+})
+
+// autoStartedResource is a cloud resource whose provider started the given instances on its own.
+type autoStartedResource struct {
+	clients.CloudResource
+	started []string
+}
+
+func (r *autoStartedResource) StopAutoStarted() ([]string, error) {
+	stopped := r.started
+	r.started = nil
+	return stopped, nil
+}
+
+var _ = Describe("Resource auto-starts", func() {
+	It("Should count the instances stopped again and warn about them", func() {
+		ctx := context.Background()
+		recorder := record.NewFakeRecorder(2)
+		r := &ResourceReconciler{Recorder: recorder}
+		resource := &managerv1.Resource{
+			ObjectMeta: metav1.ObjectMeta{Name: "rds.analytics", Namespace: "default"},
+			Status:     managerv1.ResourceStatus{AutoRestarts: 1, LastAutoRestart: "2030-01-03T12:00:00Z"},
+		}
+		cloudResource := &autoStartedResource{started: []string{"analytics-1", "analytics-2"}}
+
+		before := time.Now().UTC().Truncate(time.Second)
+		Expect(r.stopAutoStarted(ctx, resource, cloudResource)).Should(BeTrue())
+		Expect(resource.Status.AutoRestarts).Should(Equal(3))
+		lastAutoRestart, err := time.Parse(time.RFC3339, resource.Status.LastAutoRestart)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(lastAutoRestart).Should(BeTemporally(">=", before))
+		Expect(recorder.Events).Should(Receive(Equal(
+			"Warning AutoStarted Stopped instances started by the cloud provider while the resource wasn't booked: analytics-1, analytics-2")))
+
+		By("By leaving the status alone when no instance was started")
+		Expect(r.stopAutoStarted(ctx, resource, cloudResource)).Should(BeFalse())
+		Expect(resource.Status.AutoRestarts).Should(Equal(3))
+		Expect(resource.Status.LastAutoRestart).Should(Equal(lastAutoRestart.Format(time.RFC3339)))
+		Expect(recorder.Events).ShouldNot(Receive())
+
+		By("By ignoring resources whose provider doesn't start them on its own")
+		Expect(r.stopAutoStarted(ctx, resource, &clients.FakeResource{})).Should(BeFalse())
+	})
 })
@@ -22,8 +22,10 @@ import (
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
 	"github.com/kotaicode/resource-booking-operator/clients"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -33,11 +35,16 @@ import (
 type ResourceMonitorReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Clients are the cloud and cluster clients that the new resources are looked up with.
+	Clients  clients.Clients
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=resourcemonitors,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=resourcemonitors/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=manager.kotaico.de,resources=resourcemonitors/finalizers,verbs=update
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -66,15 +73,17 @@ func (r *ResourceMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		clusterResources[rs.Spec.Tag] = true
 	}
 
-	monitor, err := clients.MonitorFactory(resourceMonitor.Spec.Type)
+	monitor, err := clients.MonitorFactory(resourceMonitor.Spec.Type, r.Clients)
 	if err != nil {
 		log.Error(err, err.Error())
+		r.Recorder.Event(&resourceMonitor, corev1.EventTypeWarning, "MonitorFailed", err.Error())
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
 	nonMatchingTags, err := monitor.GetNewResources(clusterResources)
 	if err != nil {
 		log.Error(err, err.Error())
+		r.Recorder.Eventf(&resourceMonitor, corev1.EventTypeWarning, "MonitorFailed", "Looking up new resources failed: %v", err)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
@@ -93,7 +102,10 @@ func (r *ResourceMonitorReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		err := r.Create(ctx, resource)
 		if err != nil {
 			log.Error(err, "Error creating resources")
+			r.Recorder.Eventf(&resourceMonitor, corev1.EventTypeWarning, "ResourceCreateFailed", "Creating resource %s failed: %v", resource.Name, err)
+			continue
 		}
+		r.Recorder.Eventf(&resourceMonitor, corev1.EventTypeNormal, "ResourceCreated", "Created resource %s", resource.Name)
 	}
 
 	return ctrl.Result{RequeueAfter: time.Duration(time.Minute * 2)}, nil
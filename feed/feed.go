@@ -0,0 +1,127 @@
+// Package feed serves the bookings as iCalendar feeds, that calendar apps can subscribe to.
+package feed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	"github.com/kotaicode/resource-booking-operator/notify"
+)
+
+// Path is the path the feed is served at.
+const Path = "/calendar.ics"
+
+// The query parameters that filter the bookings of the feed.
+const (
+	ResourceNameParam = "resource_name"
+	UserIDParam       = "user_id"
+	NamespaceParam    = "namespace"
+)
+
+// Handler renders the bookings of a namespace as an iCalendar feed. The bookings can be filtered by the
+// resource_name and user_id query parameters. The feed isn't authenticated, so it never serves the bookings of
+// another namespace, and a namespace query parameter naming one is not found.
+type Handler struct {
+	Client    client.Reader
+	Namespace string
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	log := log.FromContext(req.Context())
+
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+
+	if namespace := query.Get(NamespaceParam); namespace != "" && namespace != h.Namespace {
+		http.NotFound(w, req)
+		return
+	}
+
+	var bookings managerv1.BookingList
+	if err := h.Client.List(req.Context(), &bookings, client.InNamespace(h.Namespace)); err != nil {
+		log.Error(err, "Error listing bookings for the calendar feed")
+		http.Error(w, "Error listing bookings", http.StatusInternalServerError)
+		return
+	}
+
+	calendar := notify.Calendar{Name: "Bookings"}
+	resourceName, userID := query.Get(ResourceNameParam), query.Get(UserIDParam)
+	for _, booking := range bookings.Items {
+		if (resourceName != "" && booking.Spec.ResourceName != resourceName) || (userID != "" && booking.Spec.UserID != userID) {
+			continue
+		}
+		calendar.Bookings = append(calendar.Bookings, booking)
+	}
+
+	switch {
+	case resourceName != "" && userID != "":
+		calendar.Name = "Bookings of " + resourceName + " by " + userID
+	case resourceName != "":
+		calendar.Name = "Bookings of " + resourceName
+	case userID != "":
+		calendar.Name = "Bookings by " + userID
+	}
+
+	// A stable order keeps the feed the same between requests, as long as the bookings don't change
+	sort.Slice(calendar.Bookings, func(i, j int) bool {
+		a, b := calendar.Bookings[i], calendar.Bookings[j]
+		if a.Spec.StartAt != b.Spec.StartAt {
+			return a.Spec.StartAt < b.Spec.StartAt
+		}
+		return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+	})
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="bookings.ics"`)
+	if _, err := w.Write(calendar.Bytes()); err != nil {
+		log.Error(err, "Error writing the calendar feed")
+	}
+}
+
+// Server serves the feed on its address. It's a manager runnable that runs on every replica, not only on the leader.
+type Server struct {
+	Addr    string
+	Handler http.Handler
+}
+
+// Start serves the feed until the context is done.
+func (s *Server) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s.Handler)
+
+	srv := &http.Server{Addr: s.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+// NeedLeaderElection reports that the feed is served by all replicas.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
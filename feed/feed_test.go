@@ -0,0 +1,121 @@
+package feed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+func newTestBooking(name, resourceName, userID, startAt string) *managerv1.Booking {
+	return &managerv1.Booking{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+		Spec: managerv1.BookingSpec{
+			ResourceName: resourceName,
+			UserID:       userID,
+			StartAt:      startAt,
+			EndAt:        strings.Replace(startAt, "T10:", "T12:", 1),
+		},
+	}
+}
+
+func newTestHandler(t *testing.T, objs ...client.Object) *Handler {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := managerv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return &Handler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(), Namespace: "default"}
+}
+
+func TestHandler(t *testing.T) {
+	h := newTestHandler(t,
+		newTestBooking("analytics-alice", "ec2.analytics", "alice", "2030-01-11T10:00:00Z"),
+		newTestBooking("analytics-bob", "ec2.analytics", "bob", "2030-01-10T10:00:00Z"),
+		newTestBooking("reporting-alice", "ec2.reporting", "alice", "2030-01-12T10:00:00Z"),
+		otherNamespace(newTestBooking("analytics-carol", "ec2.analytics", "carol", "2030-01-13T10:00:00Z")),
+	)
+
+	tests := []struct {
+		name     string
+		query    string
+		calName  string
+		bookings []string
+	}{
+		{"all", "", "Bookings", []string{"analytics-bob", "analytics-alice", "reporting-alice"}},
+		{"resource", "?resource_name=ec2.analytics", "Bookings of ec2.analytics", []string{"analytics-bob", "analytics-alice"}},
+		{"user", "?user_id=alice", "Bookings by alice", []string{"analytics-alice", "reporting-alice"}},
+		{"resource and user", "?resource_name=ec2.analytics&user_id=alice", "Bookings of ec2.analytics by alice", []string{"analytics-alice"}},
+		{"namespace", "?namespace=default", "Bookings", []string{"analytics-bob", "analytics-alice", "reporting-alice"}},
+		{"no match", "?user_id=carol", "Bookings by carol", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path+tt.query, nil))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("got status %d, want %d", rec.Code, http.StatusOK)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "text/calendar; charset=utf-8" {
+				t.Errorf("got content type %q", got)
+			}
+
+			ics := rec.Body.String()
+			if !strings.Contains(ics, "X-WR-CALNAME:"+tt.calName+"\r\n") {
+				t.Errorf("calendar isn't named %q:\n%s", tt.calName, ics)
+			}
+			if strings.Contains(ics, "METHOD:") {
+				t.Errorf("feed has a method:\n%s", ics)
+			}
+
+			var uids []string
+			for _, line := range strings.Split(ics, "\r\n") {
+				if uid, ok := strings.CutPrefix(line, "UID:uid-"); ok {
+					uids = append(uids, strings.TrimSuffix(uid, "@resource-booking-operator"))
+				}
+			}
+			if strings.Join(uids, ",") != strings.Join(tt.bookings, ",") {
+				t.Errorf("got bookings %v, want %v", uids, tt.bookings)
+			}
+		})
+	}
+}
+
+func otherNamespace(booking *managerv1.Booking) *managerv1.Booking {
+	booking.Namespace = "other"
+	return booking
+}
+
+func TestHandlerOtherNamespace(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestHandler(t, otherNamespace(newTestBooking("analytics-carol", "ec2.analytics", "carol", "2030-01-13T10:00:00Z"))).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path+"?namespace=other", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if strings.Contains(rec.Body.String(), "carol") {
+		t.Errorf("response shows a booking of another namespace:\n%s", rec.Body.String())
+	}
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestHandler(t).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path, nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -19,23 +19,34 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	"github.com/kotaicode/resource-booking-operator/clients"
 	"github.com/kotaicode/resource-booking-operator/controllers"
+	"github.com/kotaicode/resource-booking-operator/feed"
+	"github.com/kotaicode/resource-booking-operator/notify"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,6 +66,13 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var calendarAddr string
+	var bookingMaxDuration time.Duration
+	var bookingMaxPriority int
+	var fakeInstances string
+	var notificationTemplates string
+	var smtpSecret string
+	var fakeOpts clients.FakeCloudOptions
 
 	namespace := os.Getenv("NAMESPACE")
 	if namespace == "" {
@@ -63,9 +81,28 @@ func main() {
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&calendarAddr, "calendar-bind-address", "0",
+		"The address the unauthenticated iCalendar feed of the bookings in the operator namespace binds to, e.g. :8082. "+
+			"The feed is disabled by default.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&bookingMaxDuration, "booking-max-duration", 0,
+		"The longest time a single booking can span, e.g. 72h. Zero means there is no limit.")
+	flag.IntVar(&bookingMaxPriority, "booking-max-priority", 0,
+		"The highest priority a booking can be given. Zero means that bookings can't preempt others.")
+	flag.StringVar(&notificationTemplates, "notification-templates", "",
+		"The name of a ConfigMap in the operator namespace with templates of the notification content.")
+	flag.StringVar(&smtpSecret, "smtp-secret", "",
+		"The name of a Secret in the operator namespace with the SMTP settings of email notifications. "+
+			"Defaults to the SMTP_* environment variables.")
+	flag.StringVar(&fakeInstances, "fake-instances", "",
+		"Enables the in-memory fake resource type with the given resources and instance counts, e.g. analytics=2,reporting=1. "+
+			"Meant for local development and tests only.")
+	flag.DurationVar(&fakeOpts.StartLatency, "fake-start-latency", 10*time.Second, "How long fake instances take to start.")
+	flag.DurationVar(&fakeOpts.StopLatency, "fake-stop-latency", 10*time.Second, "How long fake instances take to stop.")
+	flag.Float64Var(&fakeOpts.FailureRate, "fake-failure-rate", 0,
+		"The probability, from 0 to 1, that starting or stopping fake instances fails.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -107,36 +144,89 @@ func main() {
 		os.Exit(1)
 	}
 
+	cloudClients := newCloudClients()
+
+	if fakeInstances != "" {
+		cloudClients.Fake = clients.NewFakeCloud(fakeOpts)
+		if err = cloudClients.Fake.AddManagedInstances(fakeInstances); err != nil {
+			setupLog.Error(err, "unable to create fake instances")
+			os.Exit(1)
+		}
+	}
+
+	// The manager cache only covers the operator namespace, while bookable workloads can live in any namespace.
+	cloudClients.Workload, err = client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		setupLog.Error(err, "unable to create workload client")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.ResourceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Clients:  cloudClients,
+		Recorder: mgr.GetEventRecorderFor("resource-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Resource")
 		os.Exit(1)
 	}
 	if err = (&controllers.BookingReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		NotificationTemplates: types.NamespacedName{Namespace: namespace, Name: notificationTemplates},
+		SMTP:                  notify.EmailConfigFromEnv(),
+		SMTPSecret:            types.NamespacedName{Namespace: namespace, Name: smtpSecret},
+		Clients:               cloudClients,
+		Recorder:              mgr.GetEventRecorderFor("booking-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Booking")
 		os.Exit(1)
 	}
 	if err = (&controllers.ResourceMonitorReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Clients:  cloudClients,
+		Recorder: mgr.GetEventRecorderFor("resourcemonitor-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ResourceMonitor")
 		os.Exit(1)
 	}
 	if err = (&controllers.BookingSchedulerReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("bookingscheduler-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BookingScheduler")
+		os.Exit(1)
+	}
+	if err = (&controllers.BookingQuotaReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "BookingScheduler")
+		setupLog.Error(err, "unable to create controller", "controller", "BookingQuota")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&managerv1.Booking{}).SetupWebhookWithManager(mgr, managerv1.BookingWebhookOptions{
+			MaxDuration: bookingMaxDuration,
+			MaxPriority: int32(bookingMaxPriority),
+		}); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Booking")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if calendarAddr != "0" {
+		if err := mgr.Add(&feed.Server{
+			Addr:    calendarAddr,
+			Handler: &feed.Handler{Client: mgr.GetClient(), Namespace: namespace},
+		}); err != nil {
+			setupLog.Error(err, "unable to set up calendar feed")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -152,3 +242,33 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newCloudClients creates the clients of the cloud providers that are configured in the environment.
+// A provider that can't be set up only disables its resource types, so the operator still starts without it.
+func newCloudClients() clients.Clients {
+	var cloudClients clients.Clients
+
+	awsCfg, err := clients.LoadAWSConfig(os.Getenv("AWS_ASSUME_ROLE_ARN"))
+	if err != nil {
+		setupLog.Error(err, "unable to load AWS config, AWS resource types are disabled")
+	} else {
+		cloudClients.EC2 = ec2.NewFromConfig(awsCfg)
+		cloudClients.RDS = rds.NewFromConfig(awsCfg)
+		cloudClients.ECS = ecs.NewFromConfig(awsCfg)
+		cloudClients.ASG = autoscaling.NewFromConfig(awsCfg)
+	}
+
+	if project := os.Getenv("GCE_PROJECT"); project != "" {
+		if cloudClients.GCE, err = clients.NewGCEClient(project); err != nil {
+			setupLog.Error(err, "Compute Engine resource types are disabled")
+		}
+	}
+
+	if subscriptionID := os.Getenv("AZURE_SUBSCRIPTION_ID"); subscriptionID != "" {
+		if cloudClients.AzureVM, err = clients.NewAzureVMClient(subscriptionID); err != nil {
+			setupLog.Error(err, "Azure resource types are disabled")
+		}
+	}
+
+	return cloudClients
+}
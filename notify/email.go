@@ -1,55 +1,360 @@
 package notify
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"os"
+	"strings"
+	"time"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
 )
 
+// The TLS modes of SMTP connections.
+const (
+	// SMTPTLSStartTLS upgrades the connection with STARTTLS, and fails when the server doesn't support it.
+	SMTPTLSStartTLS = "starttls"
+	// SMTPTLSImplicit connects with TLS right away, usually to port 465.
+	SMTPTLSImplicit = "tls"
+	// SMTPTLSNone never encrypts the connection, and is only meant for local relays.
+	SMTPTLSNone = "none"
+)
+
+// The keys of the Secrets that hold SMTP settings.
+const (
+	SecretSMTPHost     = "host"
+	SecretSMTPPort     = "port"
+	SecretSMTPUsername = "username"
+	SecretSMTPPassword = "password"
+	SecretSMTPSender   = "sender"
+	SecretSMTPTLS      = "tls"
+)
+
+// smtpTimeout bounds connecting to the SMTP server.
+const smtpTimeout = 10 * time.Second
+
 // EmailConfig holds all email configuration data
 type EmailConfig struct {
 	Host     string
 	Port     string
 	Username string
 	Password string
+	// Sender is the address emails are sent from.
+	Sender string
+	// TLS is the TLS mode, one of starttls, tls and none. When empty, the connection is upgraded with STARTTLS
+	// only if the server supports it, like net/smtp.SendMail does.
+	TLS string
+	// TLSConfig overrides the TLS settings, like the trusted certificate authorities.
+	// Defaults to verifying the server against the system certificate authorities.
+	TLSConfig *tls.Config
+}
+
+// EmailConfigFromEnv returns the SMTP settings of the SMTP_HOST, SMTP_PORT, SMTP_USER, SMTP_PASSWORD, SMTP_SENDER
+// and SMTP_TLS environment variables.
+func EmailConfigFromEnv() EmailConfig {
+	return EmailConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USER"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Sender:   os.Getenv("SMTP_SENDER"),
+		TLS:      os.Getenv("SMTP_TLS"),
+	}
+}
+
+// NewEmailConfig returns the SMTP settings held by the data of a Secret.
+func NewEmailConfig(secret map[string][]byte) (EmailConfig, error) {
+	config := EmailConfig{
+		Host:     string(secret[SecretSMTPHost]),
+		Port:     string(secret[SecretSMTPPort]),
+		Username: string(secret[SecretSMTPUsername]),
+		Password: string(secret[SecretSMTPPassword]),
+		Sender:   string(secret[SecretSMTPSender]),
+		TLS:      string(secret[SecretSMTPTLS]),
+	}
+
+	if config.Host == "" {
+		return config, fmt.Errorf("the SMTP secret has no %s", SecretSMTPHost)
+	}
+
+	return config, nil
 }
 
 // Email holds all email data
 type Email struct {
 	Recipient, Subject, HTMLBody, TextBody, Sender string
 	Config                                         EmailConfig
+	Templates                                      *Templates
+	// Calendar is the iCalendar attached to the email, sent with the CalendarMethod, if any.
+	Calendar       []byte
+	CalendarMethod string
+
+	err error
 }
 
-// Prepare prepares the email notification, by using the passed booking, to set the Email fields
-func (e *Email) Prepare(booking managerv1.Booking) Notifier {
-	e.Subject = "Notice: Your resource instances will be stopped in 20 minutes."
-	e.HTMLBody = fmt.Sprintf("<p>Your booking for resource <strong>%s</strong> expires in 20 minutes and the resource will be stopped. Please, extend the booking if you want to keep the resource instances running.</p>", booking.Spec.ResourceName)
-	e.TextBody = fmt.Sprintf("Your booking for resource %s expires in 20 minutes and the resource will be stopped. Please, extend the booking if you want to keep the resource instances running.", booking.Spec.ResourceName)
-	e.Sender = os.Getenv("SMTP_SENDER")
+// Prepare prepares the email notification, by using the passed event, to set the Email fields
+func (e *Email) Prepare(event Event) Notifier {
+	m, err := e.Templates.render(event)
+	e.err = err
+	e.Subject = m.Subject
+	e.HTMLBody = m.HTMLBody()
+	e.TextBody = m.Text()
+	e.Sender = e.Config.Sender
 
-	e.Config.Host = os.Getenv("SMTP_HOST")
-	e.Config.Port = os.Getenv("SMTP_PORT")
-	e.Config.Username = os.Getenv("SMTP_USER")
-	e.Config.Password = os.Getenv("SMTP_PASSWORD")
+	e.Calendar, e.CalendarMethod = nil, calendarMethod(event.Name)
+	if e.CalendarMethod != "" {
+		e.Calendar = Calendar{
+			Method:    e.CalendarMethod,
+			Organizer: e.Sender,
+			Attendee:  e.Recipient,
+			Bookings:  []managerv1.Booking{event.Booking},
+		}.Bytes()
+	}
 
 	return e
 }
 
-// Send sends the email notification
+// Send sends the email notification as a multipart message with a text and an HTML alternative, and the calendar
+// as an attachment.
+// The server is only authenticated with when the config has a username.
 func (e *Email) Send() error {
-	from := fmt.Sprintf("From: %s\r\n", e.Sender)
-	to := fmt.Sprintf("To: %s\r\n", e.Recipient)
-	subj := fmt.Sprintf("Subject: %s\r\n\r\n", e.Subject)
-	body := fmt.Sprintf("%s\r\n", e.TextBody)
-
-	msg := []byte(fmt.Sprintf("%s%s%s%s", from, to, subj, body))
-	auth := smtp.PlainAuth("", e.Config.Username, e.Config.Password, e.Config.Host)
-	err := smtp.SendMail(fmt.Sprintf("%s:%s", e.Config.Host, e.Config.Port), auth, e.Sender, []string{e.Recipient}, msg)
+	if e.err != nil {
+		return e.err
+	}
+
+	msg, err := e.message()
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Config.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if e.Config.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Config.Username, e.Config.Password, e.Config.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(e.Sender)); err != nil {
+		return err
+	}
+	if err := c.Rcpt(envelopeAddress(e.Recipient)); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// dial connects to the SMTP server, encrypting the connection as the TLS mode says.
+func (c EmailConfig) dial() (*smtp.Client, error) {
+	addr := net.JoinHostPort(c.Host, c.Port)
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+
+	tlsConfig := c.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{ServerName: c.Host}
+	}
+
+	switch c.TLS {
+	case SMTPTLSImplicit:
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, c.Host)
+	case SMTPTLSStartTLS, SMTPTLSNone, "":
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := smtp.NewClient(conn, c.Host)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if c.TLS != SMTPTLSNone {
+			ok, _ := client.Extension("STARTTLS")
+			if !ok && c.TLS == SMTPTLSStartTLS {
+				client.Close()
+				return nil, fmt.Errorf("SMTP server %s doesn't support STARTTLS", addr)
+			}
+			if !ok {
+				return client, nil
+			}
+			if err := client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown SMTP TLS mode %q, expected one of %s, %s and %s", c.TLS, SMTPTLSStartTLS, SMTPTLSImplicit, SMTPTLSNone)
+	}
+}
+
+// message returns the email as a MIME message, with the text and HTML bodies as multipart/alternative parts.
+// Emails with a calendar are multipart/mixed messages of the alternative bodies and the calendar.
+func (e *Email) message() ([]byte, error) {
+	body, contentType, err := e.alternativeBody()
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Calendar != nil {
+		if body, contentType, err = e.mixedBody(body, contentType); err != nil {
+			return nil, err
+		}
+	}
+
+	var msg bytes.Buffer
+	headers := []struct{ key, value string }{
+		{"From", e.Sender},
+		{"To", e.Recipient},
+		{"Subject", mime.QEncoding.Encode("utf-8", e.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(e.Sender)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", contentType},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h.key, h.value)
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body)
+
+	return msg.Bytes(), nil
+}
+
+// alternativeBody returns the multipart/alternative body of the text and HTML bodies, along with its content type.
+func (e *Email) alternativeBody() ([]byte, string, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain", e.TextBody},
+		{"text/html", e.HTMLBody},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if err := writeQuotedPrintable(w, part.content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": body.Boundary()}), nil
+}
+
+// mixedBody returns the multipart/mixed body of the alternative body and the calendar, along with its content type.
+func (e *Email) mixedBody(alternative []byte, alternativeType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+	body := multipart.NewWriter(&buf)
+
+	w, err := body.CreatePart(textproto.MIMEHeader{"Content-Type": {alternativeType}})
 	if err != nil {
+		return nil, "", err
+	}
+	if _, err := w.Write(alternative); err != nil {
+		return nil, "", err
+	}
+
+	w, err = body.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("text/calendar", map[string]string{"charset": "utf-8", "method": e.CalendarMethod})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": "booking.ics"})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := writeBase64(w, e.Calendar); err != nil {
+		return nil, "", err
+	}
+
+	if err := body.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": body.Boundary()}), nil
+}
+
+// writeBase64 writes the content base64 encoded, in lines of 76 characters.
+func writeBase64(w io.Writer, content []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
 		return err
 	}
+	return qp.Close()
+}
+
+// messageID returns a unique Message-ID in the domain of the sender.
+// envelopeAddress returns the bare address of an email address that can have a display name, like
+// "Bookings <bookings@example.com>", for the SMTP envelope.
+func envelopeAddress(address string) string {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return address
+	}
+	return addr.Address
+}
+
+func messageID(sender string) string {
+	domain := "resource-booking-operator"
+	if _, after, ok := strings.Cut(sender, "@"); ok && after != "" {
+		domain = strings.TrimRight(after, ">")
+	}
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
 
-	return nil
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)
 }
@@ -0,0 +1,398 @@
+package notify
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+// smtpStub is a minimal SMTP server that accepts a single message per connection and records it.
+type smtpStub struct {
+	listener net.Listener
+	tls      *tls.Config
+	// startTLS advertises STARTTLS on plain connections.
+	startTLS bool
+
+	mu       sync.Mutex
+	auth     string
+	from, to string
+	data     string
+	secure   bool
+}
+
+// newSMTPStub starts a stub on a random local port. Connections are wrapped in TLS right away when implicitTLS is set.
+func newSMTPStub(t *testing.T, implicitTLS, startTLS bool) (*smtpStub, *tls.Config) {
+	t.Helper()
+
+	serverTLS, clientTLS := stubTLSConfigs(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	if implicitTLS {
+		listener = tls.NewListener(listener, serverTLS)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	s := &smtpStub{listener: listener, tls: serverTLS, startTLS: startTLS}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, implicitTLS)
+		}
+	}()
+
+	return s, clientTLS
+}
+
+func (s *smtpStub) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *smtpStub) serve(conn net.Conn, secure bool) {
+	defer conn.Close()
+
+	r, w := bufio.NewReader(conn), conn
+	reply := func(line string) { io.WriteString(w, line+"\r\n") }
+
+	reply("220 stub ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			if s.startTLS && !secure {
+				reply("250-stub")
+				reply("250-AUTH PLAIN")
+				reply("250 STARTTLS")
+			} else {
+				reply("250-stub")
+				reply("250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			reply("220 ready")
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tlsConn, true
+			r, w = bufio.NewReader(tlsConn), tlsConn
+		case "AUTH":
+			s.mu.Lock()
+			s.auth = strings.TrimPrefix(cmd, "AUTH PLAIN ")
+			s.mu.Unlock()
+			reply("235 authenticated")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = cmd
+			s.mu.Unlock()
+			reply("250 ok")
+		case "RCPT":
+			s.mu.Lock()
+			s.to = cmd
+			s.mu.Unlock()
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data, s.secure = data.String(), secure
+			s.mu.Unlock()
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// stubTLSConfigs returns the TLS config of a server with a self-signed certificate for 127.0.0.1,
+// and the TLS config of a client that trusts it.
+func stubTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "smtp stub"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing certificate: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{ServerName: "127.0.0.1", RootCAs: pool}
+
+	return server, client
+}
+
+func sendTestEmail(config EmailConfig) error {
+	n, err := NewNotifier(managerv1.Notification{Type: managerv1.NotificationEmail, Recipient: "alice@example.com"}, nil, Options{SMTP: config})
+	if err != nil {
+		return err
+	}
+	return n.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: newTestBooking()}).Send()
+}
+
+func TestEmailSendModes(t *testing.T) {
+	tests := []struct {
+		name                  string
+		implicitTLS, startTLS bool
+		config                EmailConfig
+		wantSecure            bool
+		wantAuth              bool
+	}{
+		{"no TLS and no auth", false, true, EmailConfig{TLS: SMTPTLSNone}, false, false},
+		{"opportunistic STARTTLS", false, true, EmailConfig{}, true, false},
+		{"STARTTLS with auth", false, true, EmailConfig{TLS: SMTPTLSStartTLS, Username: "operator", Password: "s3cret"}, true, true},
+		{"implicit TLS with auth", true, false, EmailConfig{TLS: SMTPTLSImplicit, Username: "operator", Password: "s3cret"}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, clientTLS := newSMTPStub(t, tt.implicitTLS, tt.startTLS)
+			config := tt.config
+			config.Host, config.Port, config.Sender, config.TLSConfig = "127.0.0.1", stub.port(), "bookings@example.com", clientTLS
+
+			if err := sendTestEmail(config); err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+
+			if stub.secure != tt.wantSecure {
+				t.Errorf("message sent over TLS = %v, want %v", stub.secure, tt.wantSecure)
+			}
+			wantAuth := ""
+			if tt.wantAuth {
+				wantAuth = base64.StdEncoding.EncodeToString([]byte("\x00operator\x00s3cret"))
+			}
+			if stub.auth != wantAuth {
+				t.Errorf("AUTH = %q, want %q", stub.auth, wantAuth)
+			}
+			if stub.from != "MAIL FROM:<bookings@example.com>" || !strings.HasPrefix(stub.to, "RCPT TO:<alice@example.com>") {
+				t.Errorf("envelope = %q, %q", stub.from, stub.to)
+			}
+		})
+	}
+}
+
+func TestEmailSendDisplayName(t *testing.T) {
+	stub, _ := newSMTPStub(t, false, false)
+	config := EmailConfig{Host: "127.0.0.1", Port: stub.port(), Sender: "Bookings <bookings@example.com>", TLS: SMTPTLSNone}
+
+	if err := sendTestEmail(config); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+
+	if stub.from != "MAIL FROM:<bookings@example.com>" {
+		t.Errorf("envelope sender = %q, want the bare address", stub.from)
+	}
+	if !strings.Contains(stub.data, "From: Bookings <bookings@example.com>\r\n") {
+		t.Errorf("message doesn't keep the display name of the sender:\n%s", stub.data)
+	}
+}
+
+func TestEmailSendStartTLSRequired(t *testing.T) {
+	stub, clientTLS := newSMTPStub(t, false, false)
+	config := EmailConfig{Host: "127.0.0.1", Port: stub.port(), Sender: "bookings@example.com", TLS: SMTPTLSStartTLS, TLSConfig: clientTLS}
+
+	if err := sendTestEmail(config); err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("Send() error = %v, want STARTTLS to be required", err)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	stub, _ := newSMTPStub(t, false, false)
+	config := EmailConfig{Host: "127.0.0.1", Port: stub.port(), Sender: "bookings@example.com", TLS: SMTPTLSNone}
+
+	if err := sendTestEmail(config); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	data := stub.data
+	stub.mu.Unlock()
+
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || !strings.HasPrefix(subject, "Notice: Your resource instances will be stopped") {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	if msg.Header.Get("Message-ID") == "" || !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("Message-ID = %q, want an ID in the domain of the sender", msg.Header.Get("Message-ID"))
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v, want multipart/alternative", mediaType, err)
+	}
+
+	parts := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("reading part: %v", err)
+		}
+		// NextPart decodes quoted-printable parts
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+
+	if !strings.Contains(parts["text/plain"], "Your booking for resource ec2.analytics expires in") {
+		t.Errorf("text part = %q", parts["text/plain"])
+	}
+	if !strings.HasPrefix(parts["text/html"], "<p>Your booking for resource ec2.analytics") {
+		t.Errorf("html part = %q", parts["text/html"])
+	}
+}
+
+func TestEmailCalendarAttachment(t *testing.T) {
+	e := &Email{Recipient: "alice@example.com", Config: EmailConfig{Sender: "bookings@example.com"}}
+	booking := newTestBooking()
+	booking.UID = "0b6cbc3e"
+	e.Prepare(Event{Name: managerv1.NotificationEventScheduled, Booking: booking})
+
+	data, err := e.message()
+	if err != nil {
+		t.Fatalf("message() error = %v", err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, %v, want multipart/mixed", mediaType, err)
+	}
+
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	if part, err := reader.NextPart(); err != nil || !strings.HasPrefix(part.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Fatalf("first part = %v, %v, want the alternative bodies", part, err)
+	}
+
+	part, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("reading calendar part: %v", err)
+	}
+	calendarType, calendarParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if calendarType != "text/calendar" || calendarParams["method"] != CalendarRequest {
+		t.Errorf("calendar Content-Type = %q", part.Header.Get("Content-Type"))
+	}
+
+	encoded, _ := io.ReadAll(part)
+	ics, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || !strings.Contains(string(ics), "UID:0b6cbc3e@resource-booking-operator") {
+		t.Errorf("calendar = %q, %v, want the event of the booking", ics, err)
+	}
+
+	e.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: booking})
+	if e.Calendar != nil {
+		t.Error("emails about the expiring event shouldn't have a calendar")
+	}
+
+	e.Prepare(Event{Name: managerv1.NotificationEventCancelled, Booking: booking})
+	if e.CalendarMethod != CalendarCancel || !strings.Contains(string(e.Calendar), "STATUS:CANCELLED\r\n") {
+		t.Errorf("emails about the cancelled event should cancel the calendar entry, got %s:\n%s", e.CalendarMethod, e.Calendar)
+	}
+
+	e.Prepare(Event{Name: managerv1.NotificationEventPreempted, Booking: booking})
+	if e.CalendarMethod != CalendarCancel {
+		t.Errorf("emails about the preempted event should cancel the calendar entry, got %s", e.CalendarMethod)
+	}
+
+	booking.Generation++
+	booking.Spec.EndAt = "2030-01-10T12:30:00Z"
+	e.Prepare(Event{Name: managerv1.NotificationEventUpdated, Booking: booking})
+	if e.CalendarMethod != CalendarRequest || !strings.Contains(string(e.Calendar), "DTEND:20300110T123000Z\r\n") ||
+		!strings.Contains(string(e.Calendar), fmt.Sprintf("SEQUENCE:%d\r\n", booking.Generation)) {
+		t.Errorf("emails about the updated event should update the calendar entry, got %s:\n%s", e.CalendarMethod, e.Calendar)
+	}
+}
+
+func TestNewEmailConfig(t *testing.T) {
+	config, err := NewEmailConfig(map[string][]byte{
+		SecretSMTPHost: []byte("smtp.example.com"), SecretSMTPPort: []byte("465"), SecretSMTPTLS: []byte(SMTPTLSImplicit),
+	})
+	if err != nil {
+		t.Fatalf("NewEmailConfig() error = %v", err)
+	}
+	if config.Host != "smtp.example.com" || config.Port != "465" || config.TLS != SMTPTLSImplicit || config.Username != "" {
+		t.Errorf("NewEmailConfig() = %+v", config)
+	}
+
+	if _, err := NewEmailConfig(map[string][]byte{SecretSMTPPort: []byte("25")}); err == nil {
+		t.Error("NewEmailConfig() without a host should fail")
+	}
+}
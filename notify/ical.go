@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+// The iTIP methods of calendars sent to users.
+const (
+	// CalendarRequest invites to, or updates, the events of the calendar.
+	CalendarRequest = "REQUEST"
+	// CalendarCancel cancels the events of the calendar.
+	CalendarCancel = "CANCEL"
+)
+
+const (
+	calendarProdID     = "-//kotaicode//resource-booking-operator//EN"
+	calendarUIDDomain  = "resource-booking-operator"
+	calendarTimeLayout = "20060102T150405Z"
+	// calendarLineLength is the longest a content line can be, in octets, before it has to be folded.
+	calendarLineLength = 75
+)
+
+// Calendar is an RFC 5545 iCalendar object with a VEVENT for each booking. The UID of each event is derived from
+// the UID of its booking, and its sequence from the generation of the booking, so calendars sent about the same
+// booking update the same calendar entry.
+type Calendar struct {
+	// Method is the iTIP method of the calendar, like CalendarRequest. Calendars that aren't sent to users,
+	// like feeds, have none.
+	Method string
+	// Name is the name calendar apps show for the calendar, like for feeds.
+	Name string
+	// Organizer and Attendee are the email addresses of the sender and the recipient of calendars sent to users.
+	Organizer, Attendee string
+	Bookings            []managerv1.Booking
+}
+
+// Bytes returns the calendar in the iCalendar format. Bookings with unparsable dates are left out.
+func (c Calendar) Bytes() []byte {
+	var buf bytes.Buffer
+	line := func(name, value string) { writeCalendarLine(&buf, name+":"+value) }
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", calendarProdID)
+	if c.Method != "" {
+		line("METHOD", c.Method)
+	}
+	if c.Name != "" {
+		line("X-WR-CALNAME", escapeCalendarText(c.Name))
+	}
+
+	stamp := time.Now().UTC().Format(calendarTimeLayout)
+	for _, booking := range c.Bookings {
+		start, end, err := booking.Window()
+		if err != nil {
+			continue
+		}
+
+		status := "CONFIRMED"
+		if c.Method == CalendarCancel {
+			status = "CANCELLED"
+		}
+
+		line("BEGIN", "VEVENT")
+		line("UID", CalendarUID(booking))
+		line("SEQUENCE", fmt.Sprint(booking.Generation))
+		line("DTSTAMP", stamp)
+		line("DTSTART", start.UTC().Format(calendarTimeLayout))
+		line("DTEND", end.UTC().Format(calendarTimeLayout))
+		line("SUMMARY", escapeCalendarText(fmt.Sprintf("Booking of %s", booking.Spec.ResourceName)))
+		line("DESCRIPTION", escapeCalendarText(fmt.Sprintf("Booking %s of resource %s by %s.",
+			booking.Name, booking.Spec.ResourceName, booking.Spec.UserID)))
+		line("STATUS", status)
+		if c.Organizer != "" {
+			writeCalendarLine(&buf, "ORGANIZER"+calendarAddress(c.Organizer))
+		}
+		if c.Attendee != "" {
+			writeCalendarLine(&buf, "ATTENDEE;RSVP=FALSE"+calendarAddress(c.Attendee))
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+
+	return buf.Bytes()
+}
+
+// CalendarUID returns the UID of the calendar event of the booking.
+func CalendarUID(booking managerv1.Booking) string {
+	uid := string(booking.UID)
+	if uid == "" {
+		uid = booking.Namespace + "/" + booking.Name
+	}
+	return uid + "@" + calendarUIDDomain
+}
+
+// calendarMethod returns the method of the calendar to send along the notification about the event, if any.
+func calendarMethod(event string) string {
+	switch event {
+	case managerv1.NotificationEventScheduled, managerv1.NotificationEventStarted, managerv1.NotificationEventPromoted,
+		managerv1.NotificationEventUpdated:
+		return CalendarRequest
+	case managerv1.NotificationEventCancelled, managerv1.NotificationEventPreempted:
+		return CalendarCancel
+	default:
+		return ""
+	}
+}
+
+// calendarAddress returns the parameters and value of a calendar user property for the email address, which can have a
+// display name like "Bookings <bookings@example.com>". The display name is given as the common name parameter.
+func calendarAddress(address string) string {
+	addr, err := mail.ParseAddress(address)
+	if err != nil {
+		return ":mailto:" + address
+	}
+
+	if addr.Name == "" {
+		return ":mailto:" + addr.Address
+	}
+	// Parameter values can't hold double quotes, and are quoted as they can hold the separators of the line
+	return fmt.Sprintf(`;CN="%s":mailto:%s`, strings.ReplaceAll(addr.Name, `"`, "'"), addr.Address)
+}
+
+var calendarTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escapeCalendarText escapes a TEXT value.
+func escapeCalendarText(s string) string {
+	return calendarTextEscaper.Replace(s)
+}
+
+// writeCalendarLine writes a content line, folded so no line is longer than calendarLineLength octets.
+// Lines are only folded between UTF-8 sequences.
+func writeCalendarLine(buf *bytes.Buffer, content string) {
+	limit := calendarLineLength
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(content[cut]) {
+			cut--
+		}
+
+		buf.WriteString(content[:cut])
+		buf.WriteString("\r\n ")
+		content = content[cut:]
+		// Continuation lines start with a space, which counts towards their length
+		limit = calendarLineLength - 1
+	}
+
+	buf.WriteString(content)
+	buf.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
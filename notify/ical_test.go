@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+func TestCalendarBytes(t *testing.T) {
+	booking := newTestBooking()
+	booking.UID = "0b6cbc3e-5a4b-4f51-9b53-8a1a4a3b0f7e"
+	booking.Generation = 3
+	booking.Spec.UserID = "alice; the analyst, with a rather long user id that needs folding"
+
+	ics := string(Calendar{Method: CalendarRequest, Organizer: "bookings@example.com", Attendee: "alice@example.com",
+		Bookings: []managerv1.Booking{booking}}.Bytes())
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"METHOD:REQUEST\r\n",
+		"UID:0b6cbc3e-5a4b-4f51-9b53-8a1a4a3b0f7e@resource-booking-operator\r\n",
+		"SEQUENCE:3\r\n",
+		"DTSTART:20300110T100000Z\r\n",
+		"DTEND:20300110T120000Z\r\n",
+		"SUMMARY:Booking of ec2.analytics\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"ORGANIZER:mailto:bookings@example.com\r\n",
+		"ATTENDEE;RSVP=FALSE:mailto:alice@example.com\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("calendar doesn't contain %q:\n%s", want, ics)
+		}
+	}
+
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > calendarLineLength {
+			t.Errorf("line %q is longer than %d octets", line, calendarLineLength)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	if !strings.Contains(unfolded, `by alice\; the analyst\, with a rather long user id that needs folding.`) {
+		t.Errorf("description isn't escaped:\n%s", unfolded)
+	}
+}
+
+func TestCalendarAddresses(t *testing.T) {
+	booking := newTestBooking()
+
+	ics := string(Calendar{Method: CalendarRequest, Organizer: `"Ops, Bookings" <ops@example.com>`, Attendee: "Alice <alice@example.com>",
+		Bookings: []managerv1.Booking{booking}}.Bytes())
+
+	for _, want := range []string{
+		"ORGANIZER;CN=\"Ops, Bookings\":mailto:ops@example.com\r\n",
+		"ATTENDEE;RSVP=FALSE;CN=\"Alice\":mailto:alice@example.com\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Errorf("calendar doesn't contain %q:\n%s", want, ics)
+		}
+	}
+}
+
+func TestCalendarCancel(t *testing.T) {
+	booking := newTestBooking()
+	booking.UID = "0b6cbc3e"
+
+	request := string(Calendar{Method: CalendarRequest, Bookings: []managerv1.Booking{booking}}.Bytes())
+	cancel := string(Calendar{Method: CalendarCancel, Bookings: []managerv1.Booking{booking}}.Bytes())
+
+	if !strings.Contains(cancel, "METHOD:CANCEL\r\n") || !strings.Contains(cancel, "STATUS:CANCELLED\r\n") {
+		t.Errorf("cancellation isn't cancelled:\n%s", cancel)
+	}
+
+	uid := "UID:" + CalendarUID(booking) + "\r\n"
+	if !strings.Contains(request, uid) || !strings.Contains(cancel, uid) {
+		t.Errorf("the request and cancellation should have the same %q", uid)
+	}
+}
+
+func TestCalendarSkipsUnparsableBookings(t *testing.T) {
+	booking := newTestBooking()
+	booking.Spec.EndAt = "tomorrow"
+
+	if ics := string(Calendar{Bookings: []managerv1.Booking{booking}}.Bytes()); strings.Contains(ics, "BEGIN:VEVENT") {
+		t.Errorf("calendar has an event with an unparsable end:\n%s", ics)
+	}
+}
@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+// Event is a booking event to notify about. Its fields and methods are the data notification templates have access to.
+type Event struct {
+	// Name is the event, one of managerv1.NotificationEvents.
+	Name     string
+	Booking  managerv1.Booking
+	Resource managerv1.Resource
+}
+
+// message is the content of a notification about a booking event, shared by the notifiers.
+type message struct {
+	// Subject is a short title, like the subject of an email.
+	Subject string
+	// Summary says what happened, and is the body of the notification along with Action.
+	Summary string
+	// Action says what the user can do about it, if anything.
+	Action string
+	// HTML is the body of the notification where HTML is supported, like in emails. Defaults to the text in a paragraph.
+	HTML string
+}
+
+// newMessage returns the built-in content of the notification about the event.
+func newMessage(e Event) message {
+	booking, resource := e.Booking, e.Booking.Spec.ResourceName
+
+	switch e.Name {
+	case managerv1.NotificationEventScheduled:
+		return message{
+			Subject: fmt.Sprintf("Notice: Your booking for resource %s is scheduled.", resource),
+			Summary: fmt.Sprintf("Your booking for resource %s is scheduled from %s until %s.", resource, booking.Spec.StartAt, booking.Spec.EndAt),
+		}
+	case managerv1.NotificationEventStarted:
+		return message{
+			Subject: "Notice: Your resource instances are running.",
+			Summary: fmt.Sprintf("Your booking for resource %s has started and the resource instances are running until %s.", resource, booking.Spec.EndAt),
+		}
+	case managerv1.NotificationEventEnded:
+		return message{
+			Subject: "Notice: Your booking has ended.",
+			Summary: fmt.Sprintf("Your booking for resource %s has ended and the resource will be stopped.", resource),
+		}
+	case managerv1.NotificationEventStartFailed:
+		return message{
+			Subject: "Warning: Your resource instances failed to start.",
+			Summary: fmt.Sprintf("The instances of resource %s failed to start for your booking.", resource),
+			Action:  "Starting them is retried while the booking lasts. Please, check the status of the resource if they keep failing to start.",
+		}
+	case managerv1.NotificationEventCancelled:
+		return message{
+			Subject: fmt.Sprintf("Notice: Your booking for resource %s was cancelled.", resource),
+			Summary: fmt.Sprintf("Your booking for resource %s from %s until %s was cancelled and the resource will be stopped.",
+				resource, booking.Spec.StartAt, booking.Spec.EndAt),
+		}
+	case managerv1.NotificationEventPromoted:
+		return message{
+			Subject: fmt.Sprintf("Notice: Your queued booking for resource %s is up.", resource),
+			Summary: fmt.Sprintf("The bookings ahead of yours for resource %s ended, and your booking is active from %s until %s.",
+				resource, booking.Spec.StartAt, booking.Spec.EndAt),
+		}
+	case managerv1.NotificationEventPreempted:
+		return message{
+			Subject: fmt.Sprintf("Warning: Your booking for resource %s was preempted.", resource),
+			Summary: fmt.Sprintf("Your booking for resource %s from %s until %s was preempted by a booking with a higher priority, "+
+				"which took over the resource.", resource, booking.Spec.StartAt, booking.Spec.EndAt),
+			Action: "Please, book the resource again for after the preempting booking, or queue for it.",
+		}
+	case managerv1.NotificationEventUpdated:
+		return message{
+			Subject: fmt.Sprintf("Notice: Your booking for resource %s was updated.", resource),
+			Summary: fmt.Sprintf("Your booking for resource %s is now booked from %s until %s.", resource, booking.Spec.StartAt, booking.Spec.EndAt),
+		}
+	default:
+		left := e.TimeLeft()
+		return message{
+			Subject: fmt.Sprintf("Notice: Your resource instances will be stopped in %s.", left),
+			Summary: fmt.Sprintf("Your booking for resource %s expires in %s and the resource will be stopped.", resource, left),
+			Action: fmt.Sprintf("Please, extend the booking if you want to keep the resource instances running, "+
+				"like by 30 minutes with: kubectl patch booking %s -n %s --type merge -p '{\"spec\":{\"extend_by\":\"30m\"}}'",
+				booking.Name, booking.Namespace),
+		}
+	}
+}
+
+// Text returns the summary and action of the message as plain text.
+func (m message) Text() string {
+	if m.Action == "" {
+		return m.Summary
+	}
+	return m.Summary + " " + m.Action
+}
+
+// HTMLBody returns the HTML body of the message.
+func (m message) HTMLBody() string {
+	if m.HTML != "" {
+		return m.HTML
+	}
+	return fmt.Sprintf("<p>%s</p>", html.EscapeString(m.Text()))
+}
+
+// TimeLeft returns the time left until the end of the booking in words, rounded to minutes, like "1 hour 15 minutes".
+func (e Event) TimeLeft() string {
+	end, err := time.Parse(time.RFC3339, e.Booking.Spec.EndAt)
+	if err != nil {
+		return "a moment"
+	}
+
+	left := time.Until(end).Round(time.Minute)
+	if left < time.Minute {
+		return "less than a minute"
+	}
+
+	hours, minutes := int(left/time.Hour), int(left%time.Hour/time.Minute)
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, plural(hours, "hour"))
+	}
+	if minutes > 0 {
+		parts = append(parts, plural(minutes, "minute"))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", unit)
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
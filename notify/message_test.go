@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventTimeLeft(t *testing.T) {
+	tests := []struct {
+		left time.Duration
+		want string
+	}{
+		{20 * time.Minute, "20 minutes"},
+		{time.Minute, "1 minute"},
+		{time.Hour, "1 hour"},
+		{2*time.Hour + 15*time.Minute, "2 hours 15 minutes"},
+		{10 * time.Second, "less than a minute"},
+	}
+
+	for _, tt := range tests {
+		booking := newTestBooking()
+		// The extra seconds keep the rounding stable while the test runs
+		booking.Spec.EndAt = time.Now().Add(tt.left + 5*time.Second).UTC().Format(time.RFC3339)
+		if got := (Event{Booking: booking}).TimeLeft(); got != tt.want {
+			t.Errorf("TimeLeft() with %s left = %q, want %q", tt.left, got, tt.want)
+		}
+	}
+}
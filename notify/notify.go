@@ -2,23 +2,102 @@
 package notify
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"time"
 
 	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
 )
 
+const (
+	// SecretURLKey is the key of the notification Secret that holds the url of webhook based notifiers.
+	SecretURLKey = "url"
+	// SecretHMACKey is the key of the notification Secret that holds the key that signs the payloads of webhook notifications.
+	SecretHMACKey = "hmac_key"
+)
+
+// httpClient posts the notifications of the webhook based notifiers.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
 // Notifier is an interface that each type of notifier must implement.
 type Notifier interface {
-	Prepare(booking managerv1.Booking) Notifier
+	// Prepare prepares the notification about the event. Errors, like failing templates, are returned by Send.
+	Prepare(event Event) Notifier
 	Send() error
 }
 
+// Options holds the operator wide settings of the notifiers.
+type Options struct {
+	// Templates render the content of the notifications, which is built-in when nil.
+	Templates *Templates
+	// SMTP is the config of email notifications without a secret.
+	SMTP EmailConfig
+}
+
 // NewNotifier returns a new Notifier based on the type of notification.
-func NewNotifier(notification managerv1.Notification) (Notifier, error) {
+// The secret holds the data of the Secret referenced by the notification, if any. Email notifications with a secret
+// take their SMTP settings from it instead of the options.
+func NewNotifier(notification managerv1.Notification, secret map[string][]byte, opts Options) (Notifier, error) {
 	switch notification.Type {
-	case "email":
-		return &Email{Recipient: notification.Recipient}, nil
+	case managerv1.NotificationEmail:
+		config := opts.SMTP
+		if secret != nil {
+			var err error
+			if config, err = NewEmailConfig(secret); err != nil {
+				return nil, err
+			}
+		}
+		return &Email{Recipient: notification.Recipient, Config: config, Templates: opts.Templates}, nil
+	case managerv1.NotificationSlack, managerv1.NotificationTeams, managerv1.NotificationWebhook:
+		url := string(secret[SecretURLKey])
+		if url == "" {
+			return nil, fmt.Errorf("%s notifications need a secret with the webhook %s", notification.Type, SecretURLKey)
+		}
+
+		switch notification.Type {
+		case managerv1.NotificationSlack:
+			return &Slack{WebhookURL: url, Templates: opts.Templates}, nil
+		case managerv1.NotificationTeams:
+			return &Teams{WebhookURL: url, Templates: opts.Templates}, nil
+		default:
+			return &Webhook{URL: url, HMACKey: secret[SecretHMACKey]}, nil
+		}
 	default:
 		return nil, errors.New("Notifier type not found")
 	}
 }
+
+// postJSON sends the payload as JSON to the url, and fails on any non 2xx response.
+// The headers are added to the request, after being built from the encoded payload when headers isn't nil.
+func postJSON(url string, payload interface{}, headers func(body []byte) map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if headers != nil {
+		for k, v := range headers(body) {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Slack holds the message posted to a Slack incoming webhook.
+type Slack struct {
+	WebhookURL string
+	Message    SlackMessage
+	Templates  *Templates
+
+	err error
+}
+
+// SlackMessage is the payload of a Slack incoming webhook. Text is shown where the blocks can't be, like in notifications.
+type SlackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []SlackBlock `json:"blocks,omitempty"`
+}
+
+// SlackBlock is a section block of a Slack message, with markdown text.
+type SlackBlock struct {
+	Type string    `json:"type"`
+	Text SlackText `json:"text"`
+}
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Prepare prepares the Slack message about the event.
+func (s *Slack) Prepare(event Event) Notifier {
+	m, err := s.Templates.render(event)
+	s.err = err
+	booking := event.Booking
+
+	s.Message = SlackMessage{
+		Text: m.Summary,
+		Blocks: []SlackBlock{
+			slackSection(strings.Replace(m.Summary, booking.Spec.ResourceName, "*"+booking.Spec.ResourceName+"*", 1)),
+			slackSection(fmt.Sprintf("*Booking:* %s\n*From:* %s\n*Until:* %s", booking.Name, booking.Spec.StartAt, booking.Spec.EndAt)),
+		},
+	}
+
+	if m.Action != "" {
+		s.Message.Blocks = append(s.Message.Blocks, slackSection(m.Action))
+	}
+
+	return s
+}
+
+// Send posts the message to the Slack webhook.
+func (s *Slack) Send() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	return postJSON(s.WebhookURL, s.Message, nil)
+}
+
+func slackSection(text string) SlackBlock {
+	return SlackBlock{Type: "section", Text: SlackText{Type: "mrkdwn", Text: text}}
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestBooking() managerv1.Booking {
+	return managerv1.Booking{
+		ObjectMeta: metav1.ObjectMeta{Name: "analytics-jan10", Namespace: "default"},
+		Spec: managerv1.BookingSpec{
+			ResourceName: "ec2.analytics",
+			UserID:       "alice",
+			StartAt:      "2030-01-10T10:00:00Z",
+			EndAt:        "2030-01-10T12:00:00Z",
+		},
+	}
+}
+
+func TestSlackSend(t *testing.T) {
+	var received SlackMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			t.Errorf("decoding message: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	notification := managerv1.Notification{Type: managerv1.NotificationSlack, SecretRef: &corev1.LocalObjectReference{Name: "slack"}}
+	n, err := NewNotifier(notification, map[string][]byte{SecretURLKey: []byte(srv.URL)}, Options{})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	if err := n.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: newTestBooking()}).Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if !strings.Contains(received.Text, "ec2.analytics") {
+		t.Errorf("text = %q, want the resource name", received.Text)
+	}
+
+	var blocks []string
+	for _, b := range received.Blocks {
+		blocks = append(blocks, b.Text.Text)
+	}
+	all := strings.Join(blocks, "\n")
+	for _, want := range []string{"*ec2.analytics*", "2030-01-10T10:00:00Z", "2030-01-10T12:00:00Z", "kubectl patch booking analytics-jan10 -n default", `{"spec":{"extend_by":"30m"}}`} {
+		if !strings.Contains(all, want) {
+			t.Errorf("blocks = %q, want them to contain %q", all, want)
+		}
+	}
+}
+
+func TestSlackPrepareUsesActionTemplate(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{"expiring.action": "Verlängern Sie die Buchung {{.Booking.Name}}."})
+	if err != nil {
+		t.Fatalf("ParseTemplates() error = %v", err)
+	}
+
+	s := &Slack{Templates: templates}
+	s.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: newTestBooking()})
+
+	last := s.Message.Blocks[len(s.Message.Blocks)-1].Text.Text
+	if last != "Verlängern Sie die Buchung analytics-jan10." {
+		t.Errorf("last block = %q, want the action template", last)
+	}
+}
+
+func TestSlackSendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	s := &Slack{WebhookURL: srv.URL}
+	if err := s.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: newTestBooking()}).Send(); err == nil {
+		t.Error("Send() should fail when the webhook rejects the message")
+	}
+}
+
+func TestNewNotifierSlackWithoutURL(t *testing.T) {
+	if _, err := NewNotifier(managerv1.Notification{Type: managerv1.NotificationSlack}, nil, Options{}); err == nil {
+		t.Error("NewNotifier() should fail without a webhook url")
+	}
+}
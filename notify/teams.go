@@ -0,0 +1,87 @@
+package notify
+
+const teamsAdaptiveCard = "application/vnd.microsoft.card.adaptive"
+
+// Teams holds the message posted to a Microsoft Teams incoming webhook, or a Teams workflow that posts webhook messages.
+type Teams struct {
+	WebhookURL string
+	Message    TeamsMessage
+	Templates  *Templates
+
+	err error
+}
+
+// TeamsMessage is the payload of a Teams webhook, a message with a single adaptive card.
+type TeamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []TeamsAttachment `json:"attachments"`
+}
+
+type TeamsAttachment struct {
+	ContentType string    `json:"contentType"`
+	Content     TeamsCard `json:"content"`
+}
+
+// TeamsCard is an adaptive card made of text blocks and fact sets.
+type TeamsCard struct {
+	Schema  string           `json:"$schema"`
+	Type    string           `json:"type"`
+	Version string           `json:"version"`
+	Body    []TeamsCardBlock `json:"body"`
+}
+
+type TeamsCardBlock struct {
+	Type   string      `json:"type"`
+	Text   string      `json:"text,omitempty"`
+	Wrap   bool        `json:"wrap,omitempty"`
+	Weight string      `json:"weight,omitempty"`
+	Facts  []TeamsFact `json:"facts,omitempty"`
+}
+
+type TeamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Prepare prepares the Teams message about the event.
+func (t *Teams) Prepare(event Event) Notifier {
+	m, err := t.Templates.render(event)
+	t.err = err
+	booking := event.Booking
+
+	body := []TeamsCardBlock{
+		{Type: "TextBlock", Weight: "Bolder", Wrap: true, Text: m.Summary},
+		{Type: "FactSet", Facts: []TeamsFact{
+			{Title: "Booking", Value: booking.Name},
+			{Title: "From", Value: booking.Spec.StartAt},
+			{Title: "Until", Value: booking.Spec.EndAt},
+		}},
+	}
+	if m.Action != "" {
+		body = append(body, TeamsCardBlock{Type: "TextBlock", Wrap: true, Text: m.Action})
+	}
+
+	t.Message = TeamsMessage{
+		Type: "message",
+		Attachments: []TeamsAttachment{{
+			ContentType: teamsAdaptiveCard,
+			Content: TeamsCard{
+				Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+				Type:    "AdaptiveCard",
+				Version: "1.4",
+				Body:    body,
+			},
+		}},
+	}
+
+	return t
+}
+
+// Send posts the message to the Teams webhook.
+func (t *Teams) Send() error {
+	if t.err != nil {
+		return t.err
+	}
+
+	return postJSON(t.WebhookURL, t.Message, nil)
+}
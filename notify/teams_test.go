@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTeamsSend(t *testing.T) {
+	var received TeamsMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&received); err != nil {
+			t.Errorf("decoding message: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	notification := managerv1.Notification{Type: managerv1.NotificationTeams, SecretRef: &corev1.LocalObjectReference{Name: "teams"}}
+	n, err := NewNotifier(notification, map[string][]byte{SecretURLKey: []byte(srv.URL)}, Options{})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	if err := n.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: newTestBooking()}).Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(received.Attachments) != 1 || received.Attachments[0].ContentType != teamsAdaptiveCard {
+		t.Fatalf("attachments = %+v, want a single adaptive card", received.Attachments)
+	}
+
+	facts := make(map[string]string)
+	for _, block := range received.Attachments[0].Content.Body {
+		for _, f := range block.Facts {
+			facts[f.Title] = f.Value
+		}
+	}
+	want := map[string]string{"Booking": "analytics-jan10", "From": "2030-01-10T10:00:00Z", "Until": "2030-01-10T12:00:00Z"}
+	for k, v := range want {
+		if facts[k] != v {
+			t.Errorf("fact %s = %q, want %q", k, facts[k], v)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+// The parts of a message that can be templated. The HTML part is an html/template, the others are text/templates.
+const (
+	TemplateSubject = "subject"
+	TemplateSummary = "summary"
+	TemplateAction  = "action"
+	TemplateHTML    = "html"
+)
+
+var templateParts = []string{TemplateSubject, TemplateSummary, TemplateAction, TemplateHTML}
+
+// Templates holds the templates of the notification content. They are keyed like the ConfigMap they are loaded from,
+// by the event and part of the message they render, like "expiring.subject". Keys without an event, like "subject",
+// render that part for all the events without a template of their own. Parts without a template keep their built-in content.
+type Templates struct {
+	templates map[string]executor
+}
+
+// executor is the common interface of text and html templates.
+type executor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// ParseTemplates parses the templates of the data of a ConfigMap. Unknown keys are rejected, so typos don't go unnoticed.
+func ParseTemplates(data map[string]string) (*Templates, error) {
+	t := &Templates{templates: make(map[string]executor)}
+
+	for key, src := range data {
+		event, part, ok := strings.Cut(key, ".")
+		if !ok {
+			event, part = "", key
+		}
+
+		if !slices.Contains(templateParts, part) || (event != "" && !slices.Contains(managerv1.NotificationEvents, event)) {
+			return nil, fmt.Errorf("unknown notification template %q, expected a key like [<event>.]<%s>", key, strings.Join(templateParts, "|"))
+		}
+
+		var err error
+		if part == TemplateHTML {
+			t.templates[key], err = htmltemplate.New(key).Option("missingkey=error").Parse(src)
+		} else {
+			t.templates[key], err = texttemplate.New(key).Option("missingkey=error").Parse(src)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing notification template %q: %w", key, err)
+		}
+	}
+
+	return t, nil
+}
+
+// render returns the content of the notification about the event. A nil Templates renders the built-in content.
+func (t *Templates) render(e Event) (message, error) {
+	m := newMessage(e)
+	if t == nil {
+		return m, nil
+	}
+
+	parts := map[string]*string{
+		TemplateSubject: &m.Subject,
+		TemplateSummary: &m.Summary,
+		TemplateAction:  &m.Action,
+		TemplateHTML:    &m.HTML,
+	}
+	for part, out := range parts {
+		tmpl, ok := t.templates[e.Name+"."+part]
+		if !ok {
+			tmpl, ok = t.templates[part]
+		}
+		if !ok {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, e); err != nil {
+			return m, fmt.Errorf("rendering notification template: %w", err)
+		}
+		*out = strings.TrimSpace(buf.String())
+	}
+
+	return m, nil
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+)
+
+func TestTemplatesRender(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{
+		"subject":          "[Booking] {{.Booking.Spec.ResourceName}}",
+		"expiring.subject": "Ihre Buchung endet in {{.TimeLeft}}",
+		"summary":          "{{.Name}}: {{.Resource.Status.Running}} of {{.Resource.Status.Instances}} running",
+		"html":             `<a href="https://booking.example.com/{{.Booking.Namespace}}/{{.Booking.Name}}">{{.Booking.Spec.UserID}}</a>`,
+	})
+	if err != nil {
+		t.Fatalf("ParseTemplates() error = %v", err)
+	}
+
+	e := Event{Name: managerv1.NotificationEventStarted, Booking: newTestBooking()}
+	e.Booking.Spec.UserID = "<alice>"
+	e.Resource.Status.Running, e.Resource.Status.Instances = 2, 3
+
+	m, err := templates.render(e)
+	if err != nil {
+		t.Fatalf("render() error = %v", err)
+	}
+
+	want := message{
+		Subject: "[Booking] ec2.analytics",
+		Summary: "started: 2 of 3 running",
+		Action:  newMessage(e).Action,
+		HTML:    `<a href="https://booking.example.com/default/analytics-jan10">&lt;alice&gt;</a>`,
+	}
+	if m != want {
+		t.Errorf("render() = %+v, want %+v", m, want)
+	}
+
+	e.Name = managerv1.NotificationEventExpiring
+	if m, _ = templates.render(e); !strings.HasPrefix(m.Subject, "Ihre Buchung endet in") {
+		t.Errorf("render() subject = %q, want the template of the expiring event", m.Subject)
+	}
+}
+
+func TestParseTemplatesErrors(t *testing.T) {
+	for _, data := range []map[string]string{
+		{"subjct": "typo"},
+		{"paused.subject": "unknown event"},
+		{"subject": "{{.Booking"},
+	} {
+		if _, err := ParseTemplates(data); err == nil {
+			t.Errorf("ParseTemplates(%v) should fail", data)
+		}
+	}
+}
+
+func TestTemplatesExecutionErrorFailsSend(t *testing.T) {
+	templates, err := ParseTemplates(map[string]string{"summary": "{{.Booking.Spec.Missing}}"})
+	if err != nil {
+		t.Fatalf("ParseTemplates() error = %v", err)
+	}
+
+	// The request is never made, so the url doesn't need to exist
+	s := &Slack{WebhookURL: "http://127.0.0.1:0", Templates: templates}
+	if err := s.Prepare(Event{Name: managerv1.NotificationEventEnded, Booking: newTestBooking()}).Send(); err == nil || !strings.Contains(err.Error(), "rendering") {
+		t.Errorf("Send() error = %v, want the template error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// WebhookPayloadVersion is the version of the webhook payload. It changes when the meaning of a field changes,
+	// fields may be added without changing it.
+	WebhookPayloadVersion = "v1"
+	// WebhookEventHeader is the request header that holds the event of the payload.
+	WebhookEventHeader = "X-Booking-Event"
+	// WebhookSignatureHeader is the request header that holds the signature of the payload, when the notification
+	// secret has an hmac_key. The signature is "sha256=" followed by the hex encoded HMAC-SHA256 of the request body.
+	WebhookSignatureHeader = "X-Booking-Signature-256"
+)
+
+// Webhook holds the payload posted to a generic JSON webhook.
+type Webhook struct {
+	URL     string
+	HMACKey []byte
+	Payload WebhookPayload
+}
+
+// WebhookPayload is the JSON body of webhook notifications. The event is one of managerv1.NotificationEvents:
+//
+//	{
+//	  "version": "v1",
+//	  "event": "expiring",
+//	  "timestamp": "2023-01-01T23:30:00Z",
+//	  "booking": {
+//	    "name": "analytics-jan01",
+//	    "namespace": "default",
+//	    "uid": "0b6cbc3e-5a4b-4f51-9b53-8a1a4a3b0f7e",
+//	    "resource_name": "ec2.analytics",
+//	    "user_id": "cd39ad8bc3",
+//	    "start_at": "2023-01-01T20:00:00Z",
+//	    "end_at": "2023-01-01T23:50:00Z",
+//	    "status": "IN PROGRESS"
+//	  }
+//	}
+type WebhookPayload struct {
+	Version   string         `json:"version"`
+	Event     string         `json:"event"`
+	Timestamp string         `json:"timestamp"`
+	Booking   WebhookBooking `json:"booking"`
+}
+
+// WebhookBooking describes the booking of a webhook payload.
+type WebhookBooking struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	UID          string `json:"uid"`
+	ResourceName string `json:"resource_name"`
+	UserID       string `json:"user_id"`
+	StartAt      string `json:"start_at"`
+	EndAt        string `json:"end_at"`
+	Status       string `json:"status"`
+}
+
+// Prepare prepares the payload about the event.
+func (w *Webhook) Prepare(event Event) Notifier {
+	booking := event.Booking
+	w.Payload = WebhookPayload{
+		Version:   WebhookPayloadVersion,
+		Event:     event.Name,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Booking: WebhookBooking{
+			Name:         booking.Name,
+			Namespace:    booking.Namespace,
+			UID:          string(booking.UID),
+			ResourceName: booking.Spec.ResourceName,
+			UserID:       booking.Spec.UserID,
+			StartAt:      booking.Spec.StartAt,
+			EndAt:        booking.Spec.EndAt,
+			Status:       booking.Status.Status,
+		},
+	}
+
+	return w
+}
+
+// Send posts the payload to the webhook, signed when the webhook has an HMAC key.
+func (w *Webhook) Send() error {
+	return postJSON(w.URL, w.Payload, w.headers)
+}
+
+func (w *Webhook) headers(body []byte) map[string]string {
+	headers := map[string]string{WebhookEventHeader: w.Payload.Event}
+	if len(w.HMACKey) > 0 {
+		headers[WebhookSignatureHeader] = Sign(w.HMACKey, body)
+	}
+
+	return headers
+}
+
+// Sign returns the signature of a webhook request body, as sent in the WebhookSignatureHeader.
+func Sign(key, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	managerv1 "github.com/kotaicode/resource-booking-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWebhookSend(t *testing.T) {
+	key := []byte("s3cret")
+
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer srv.Close()
+
+	notification := managerv1.Notification{Type: managerv1.NotificationWebhook, SecretRef: &corev1.LocalObjectReference{Name: "hook"}}
+	n, err := NewNotifier(notification, map[string][]byte{SecretURLKey: []byte(srv.URL), SecretHMACKey: key}, Options{})
+	if err != nil {
+		t.Fatalf("NewNotifier() error = %v", err)
+	}
+
+	booking := newTestBooking()
+	booking.UID = "0b6cbc3e"
+	if err := n.Prepare(Event{Name: managerv1.NotificationEventStarted, Booking: booking}).Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	wantBooking := WebhookBooking{
+		Name: "analytics-jan10", Namespace: "default", UID: "0b6cbc3e", ResourceName: "ec2.analytics",
+		UserID: "alice", StartAt: "2030-01-10T10:00:00Z", EndAt: "2030-01-10T12:00:00Z",
+	}
+	if payload.Version != WebhookPayloadVersion || payload.Event != managerv1.NotificationEventStarted || payload.Booking != wantBooking {
+		t.Errorf("payload = %+v, want a %s event about %+v", payload, managerv1.NotificationEventStarted, wantBooking)
+	}
+
+	if got := header.Get(WebhookEventHeader); got != managerv1.NotificationEventStarted {
+		t.Errorf("%s = %q, want %q", WebhookEventHeader, got, managerv1.NotificationEventStarted)
+	}
+	if got, want := header.Get(WebhookSignatureHeader), Sign(key, body); got != want {
+		t.Errorf("%s = %q, want %q", WebhookSignatureHeader, got, want)
+	}
+}
+
+func TestWebhookSendUnsigned(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header
+	}))
+	defer srv.Close()
+
+	w := &Webhook{URL: srv.URL}
+	if err := w.Prepare(Event{Name: managerv1.NotificationEventExpiring, Booking: newTestBooking()}).Send(); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := header.Get(WebhookSignatureHeader); got != "" {
+		t.Errorf("%s = %q, want no signature without an HMAC key", WebhookSignatureHeader, got)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Generated with: printf '{"event":"expiring"}' | openssl dgst -sha256 -hmac s3cret
+	want := "sha256=0e009be37f0bcbd9fecd8f787524cd6497a37dedf0f29fb6d1982fad62ab9dc5"
+	if got := Sign([]byte("s3cret"), []byte(`{"event":"expiring"}`)); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}